@@ -0,0 +1,224 @@
+package sentigraph
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// AspectMinLength is the minimum number of characters in
+// an automatically detected aspect term.
+const AspectMinLength = 3
+
+// clauseBreaks are words which typically separate
+// clauses with independent sentiments.
+var clauseBreaks = map[string]bool{
+	"but": true, "however": true, "although": true, "though": true,
+	"whereas": true, "while": true, "yet": true,
+}
+
+// aspectStopWords are common words which are never
+// treated as aspect terms.
+var aspectStopWords = map[string]bool{}
+
+func init() {
+	for _, w := range strings.Fields(`about above after again against all also
+		and any are aren't because been before being below between both can
+		can't cannot could couldn't did didn't does doesn't doing don't down
+		during each even ever every few for from further get got had hadn't
+		has hasn't have haven't having her here hers herself him himself his
+		how i'm i've into isn't it's its itself just let's like more most
+		much must mustn't myself never nor not now off once only other ought
+		our ours ourselves out over own really same she she's should
+		shouldn't some such than that that's the their theirs them
+		themselves then there there's these they they're this those through
+		too under until very was wasn't we're were weren't what what's when
+		where which who whom why will with won't would wouldn't you you're
+		your yours yourself good great bad awful well still one two`) {
+		aspectStopWords[w] = true
+	}
+	for w := range clauseBreaks {
+		aspectStopWords[w] = true
+	}
+}
+
+// An AspectMention is an occurrence of an aspect term in
+// a piece of text, along with the sentiment of the clause
+// surrounding it.
+type AspectMention struct {
+	Aspect    string
+	Clause    string
+	Sentiment Sentiment
+}
+
+// An AspectTally counts the sentiments of the mentions of
+// each aspect.
+type AspectTally map[string]map[Sentiment]int
+
+// Add adds the mentions to the tally.
+func (a AspectTally) Add(mentions []*AspectMention) {
+	for _, m := range mentions {
+		if a[m.Aspect] == nil {
+			a[m.Aspect] = map[Sentiment]int{}
+		}
+		a[m.Aspect][m.Sentiment]++
+	}
+}
+
+// Aspects returns the aspects in the tally, sorted by
+// decreasing number of mentions.
+func (a AspectTally) Aspects() []string {
+	totals := map[string]int{}
+	var res []string
+	for aspect, counts := range a {
+		res = append(res, aspect)
+		for _, c := range counts {
+			totals[aspect] += c
+		}
+	}
+	sort.Slice(res, func(i, j int) bool {
+		if totals[res[i]] != totals[res[j]] {
+			return totals[res[i]] > totals[res[j]]
+		}
+		return res[i] < res[j]
+	})
+	return res
+}
+
+// FindAspects finds up to count candidate aspect terms in
+// the text.
+//
+// Without a part-of-speech tagger, nouns are approximated
+// by the most frequent words which are not stop words,
+// numbers, or very short words.
+func FindAspects(text string, count int) []string {
+	counts := map[string]int{}
+	for _, word := range aspectTokens(text) {
+		if isAspectCandidate(word) {
+			counts[word]++
+		}
+	}
+	var res []string
+	for word, c := range counts {
+		if c > 1 {
+			res = append(res, word)
+		}
+	}
+	sort.Slice(res, func(i, j int) bool {
+		if counts[res[i]] != counts[res[j]] {
+			return counts[res[i]] > counts[res[j]]
+		}
+		return res[i] < res[j]
+	})
+	if len(res) > count {
+		res = res[:count]
+	}
+	return res
+}
+
+// SplitClauses splits text into clauses at sentence
+// boundaries, commas, semicolons, and contrastive
+// conjunctions such as "but".
+func SplitClauses(text string) []string {
+	var res []string
+	var cur []string
+	flush := func() {
+		if len(cur) > 0 {
+			res = append(res, strings.Join(cur, " "))
+			cur = nil
+		}
+	}
+	for _, word := range strings.Fields(text) {
+		lower := strings.ToLower(strings.TrimFunc(word, unicode.IsPunct))
+		if clauseBreaks[lower] {
+			flush()
+		}
+		cur = append(cur, word)
+		if strings.ContainsAny(word[len(word)-1:], ".!?,;:") {
+			flush()
+		}
+	}
+	flush()
+	return res
+}
+
+// ExtractAspects classifies the sentiment of every clause
+// in the text which mentions one of the aspects.
+// Aspects may consist of multiple words.
+// A clause which mentions several aspects produces a
+// mention for each of them.
+func ExtractAspects(m Model, text string, aspects []string) []*AspectMention {
+	aspectWords := make([][]string, len(aspects))
+	for i, aspect := range aspects {
+		aspectWords[i] = aspectTokens(aspect)
+	}
+
+	var res []*AspectMention
+	for _, clause := range SplitClauses(text) {
+		clauseWords := aspectTokens(clause)
+		var sentiment Sentiment
+		var classified bool
+		for i, words := range aspectWords {
+			if !containsTokens(clauseWords, words) {
+				continue
+			}
+			if !classified {
+				sentiment = m.Classify(clause)
+				classified = true
+			}
+			res = append(res, &AspectMention{
+				Aspect:    aspects[i],
+				Clause:    clause,
+				Sentiment: sentiment,
+			})
+		}
+	}
+	return res
+}
+
+// aspectTokens splits text into normalized words, with
+// any surrounding punctuation (such as a clause's
+// trailing ":" or ";") removed.
+func aspectTokens(text string) []string {
+	var res []string
+	for _, word := range strings.Fields(Normalize(text)) {
+		if word = strings.TrimFunc(word, unicode.IsPunct); word != "" {
+			res = append(res, word)
+		}
+	}
+	return res
+}
+
+// containsTokens checks if a sequence of tokens appears
+// consecutively in a list of tokens.
+func containsTokens(tokens, seq []string) bool {
+	if len(seq) == 0 {
+		return false
+	}
+	for i := 0; i+len(seq) <= len(tokens); i++ {
+		match := true
+		for j, token := range seq {
+			if tokens[i+j] != token {
+				match = false
+				break
+			}
+		}
+		if match {
+			return true
+		}
+	}
+	return false
+}
+
+func isAspectCandidate(word string) bool {
+	if len(word) < AspectMinLength || aspectStopWords[word] ||
+		word == "USERNAME" || word == "URL" {
+		return false
+	}
+	for _, ch := range word {
+		if !unicode.IsLetter(ch) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,119 @@
+// Command aspects tallies the sentiment expressed toward
+// each aspect (e.g. "battery" or "screen") in a document.
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/unixpickle/sentigraph"
+)
+
+const (
+	ModelArg = 0
+	TextArg  = 1
+)
+
+func main() {
+	var aspectList string
+	var aspectFile string
+	var count int
+	var verbose bool
+	flag.StringVar(&aspectList, "aspects", "", "comma-separated list of aspects")
+	flag.StringVar(&aspectFile, "aspectfile", "", "file with one aspect per line")
+	flag.IntVar(&count, "count", 20, "number of aspects to detect automatically")
+	flag.BoolVar(&verbose, "verbose", false, "print every classified clause")
+	flag.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage:", os.Args[0], "[flags] model_file text_file")
+		fmt.Fprintln(os.Stderr, "\nFlags:")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if flag.NArg() != 2 {
+		flag.Usage()
+		os.Exit(1)
+	}
+
+	model, err := sentigraph.ReadModel(flag.Arg(ModelArg))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Failed to read model:", err)
+		os.Exit(1)
+	}
+	text, err := ioutil.ReadFile(flag.Arg(TextArg))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Failed to read text file:", err)
+		os.Exit(1)
+	}
+
+	aspects := readAspects(aspectList, aspectFile)
+	if len(aspects) == 0 {
+		aspects = sentigraph.FindAspects(string(text), count)
+	}
+
+	mentions := sentigraph.ExtractAspects(model, string(text), aspects)
+	if verbose {
+		for _, m := range mentions {
+			fmt.Printf("%s\t%d\t%s\n", m.Aspect, m.Sentiment.Score(), m.Clause)
+		}
+		fmt.Println()
+	}
+
+	tally := sentigraph.AspectTally{}
+	tally.Add(mentions)
+	printTally(tally)
+}
+
+func readAspects(list, file string) []string {
+	var res []string
+	for _, aspect := range strings.Split(list, ",") {
+		if aspect = strings.TrimSpace(aspect); aspect != "" {
+			res = append(res, aspect)
+		}
+	}
+	if file == "" {
+		return res
+	}
+	f, err := os.Open(file)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Failed to open aspect file:", err)
+		os.Exit(1)
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if aspect := strings.TrimSpace(scanner.Text()); aspect != "" {
+			res = append(res, aspect)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		fmt.Fprintln(os.Stderr, "Failed to read aspect file:", err)
+		os.Exit(1)
+	}
+	return res
+}
+
+func printTally(tally sentigraph.AspectTally) {
+	fmt.Printf("%-20s %8s %8s %8s %8s\n", "aspect", "positive", "negative",
+		"neutral", "score")
+	for _, aspect := range tally.Aspects() {
+		counts := tally[aspect]
+		var positive, negative, total, score int
+		for sentiment, c := range counts {
+			switch sentiment.Coarse() {
+			case sentigraph.Positive:
+				positive += c
+			case sentigraph.Negative:
+				negative += c
+			}
+			total += c
+			score += sentiment.Score() * c
+		}
+		fmt.Printf("%-20s %8d %8d %8d %8.2f\n", aspect, positive, negative,
+			total-positive-negative, float64(score)/float64(total))
+	}
+}
@@ -0,0 +1,137 @@
+package sentigraph
+
+import (
+	"fmt"
+	"math/rand"
+)
+
+// A BalanceMode determines how a training set with an
+// uneven number of samples per class is balanced.
+type BalanceMode int
+
+const (
+	// NoBalance leaves the samples untouched.
+	NoBalance BalanceMode = iota
+
+	// Undersample randomly drops samples from the larger
+	// classes until every class is as small as the
+	// smallest one.
+	Undersample
+
+	// Oversample randomly repeats samples from the smaller
+	// classes until every class is as large as the largest
+	// one.
+	Oversample
+
+	// Reweight leaves the samples in place but sets their
+	// weights so that every class has the same total
+	// weight.
+	Reweight
+)
+
+// BalanceModes maps balance mode names to the modes.
+var BalanceModes = map[string]BalanceMode{
+	"none":        NoBalance,
+	"undersample": Undersample,
+	"oversample":  Oversample,
+	"reweight":    Reweight,
+}
+
+// ParseBalanceMode returns the BalanceMode with the given
+// name (as listed in BalanceModes).
+func ParseBalanceMode(name string) (BalanceMode, error) {
+	mode, ok := BalanceModes[name]
+	if !ok {
+		return 0, fmt.Errorf("unknown balance mode: %s", name)
+	}
+	return mode, nil
+}
+
+// BalanceSamples balances the classes in a list of
+// samples according to the given mode.
+//
+// Random choices are made with r.
+// The original slice is not modified, but samples may be
+// shared between it and the result.
+// In Reweight mode, the result contains copies of the
+// samples, since their weights are changed.
+//
+// Classes with no samples at all are ignored, since they
+// can neither be over- nor undersampled.
+//
+// It fails if any sample has a negative weight.
+func BalanceSamples(s []*Sample, mode BalanceMode, r *rand.Rand) ([]*Sample, error) {
+	if err := checkWeights(s); err != nil {
+		return nil, err
+	}
+	byClass := map[Sentiment][]*Sample{}
+	for _, sample := range s {
+		byClass[sample.Sentiment] = append(byClass[sample.Sentiment], sample)
+	}
+
+	switch mode {
+	case Undersample:
+		minCount := len(s)
+		for _, samples := range byClass {
+			if len(samples) < minCount {
+				minCount = len(samples)
+			}
+		}
+		var res []*Sample
+		for _, sent := range FineSentiments {
+			samples := byClass[sent]
+			if len(samples) == 0 {
+				continue
+			}
+			for _, i := range r.Perm(len(samples))[:minCount] {
+				res = append(res, samples[i])
+			}
+		}
+		return res, nil
+	case Oversample:
+		var maxCount int
+		for _, samples := range byClass {
+			if len(samples) > maxCount {
+				maxCount = len(samples)
+			}
+		}
+		var res []*Sample
+		for _, sent := range FineSentiments {
+			samples := byClass[sent]
+			if len(samples) == 0 {
+				continue
+			}
+			res = append(res, samples...)
+			for i := len(samples); i < maxCount; i++ {
+				res = append(res, samples[r.Intn(len(samples))])
+			}
+		}
+		return res, nil
+	case Reweight:
+		classWeights := map[Sentiment]float64{}
+		for _, sample := range s {
+			classWeights[sample.Sentiment] += sample.TrainingWeight()
+		}
+		target := totalWeight(s) / float64(len(classWeights))
+		res := make([]*Sample, len(s))
+		for i, sample := range s {
+			newSample := *sample
+			newSample.Weight = sample.TrainingWeight() * target /
+				classWeights[sample.Sentiment]
+			res[i] = &newSample
+		}
+		return res, nil
+	default:
+		return append([]*Sample{}, s...), nil
+	}
+}
+
+// totalWeight returns the sum of the training weights of
+// the samples.
+func totalWeight(s []*Sample) float64 {
+	var res float64
+	for _, sample := range s {
+		res += sample.TrainingWeight()
+	}
+	return res
+}
@@ -1,10 +1,18 @@
 package sentigraph
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"log"
 	"math"
+	"runtime"
+	"sort"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/unixpickle/serializer"
 )
@@ -14,10 +22,15 @@ import (
 // A value of 1 is specifically called Laplace smoothing.
 const BayesSmoothing = 1
 
-// BayesMinFeatureCount is the minimum number of times a
-// feature must appear in order to be used.
+// BayesMinFeatureCount is the minimum number of samples a
+// feature must appear in order to be used, regardless of
+// the samples' weights.
 const BayesMinFeatureCount = 2
 
+// progressInterval is how often models report progress
+// while training.
+const progressInterval = time.Second / 4
+
 func init() {
 	var b Bayes
 	serializer.RegisterTypedDeserializer(b.SerializerType(), DeserializeBayes)
@@ -39,6 +52,19 @@ type Bayes struct {
 	// Features stores the unconditional probability of
 	// each feature.
 	Features map[string]float64
+
+	// Counts stores the raw statistics from which the
+	// probabilities were computed.
+	// It is nil for models which were saved without their
+	// counts (or without their occurrence counts), in
+	// which case the model cannot be updated.
+	Counts *BayesCounts `json:",omitempty"`
+
+	// KeepCounts should be set to true if Counts is to be
+	// saved with the model.
+	// The counts include rare features, so they can make
+	// the saved model much larger.
+	KeepCounts bool `json:",omitempty"`
 }
 
 // DeserializeBayes deserializes a Bayes model.
@@ -47,18 +73,58 @@ func DeserializeBayes(d []byte) (*Bayes, error) {
 	if err := json.Unmarshal(d, &res); err != nil {
 		return nil, err
 	}
+	if res.Counts != nil && res.Counts.Occurrences == nil {
+		// Counts without occurrences cannot be pruned
+		// correctly, so they are not usable.
+		res.Counts = nil
+	}
 	return &res, nil
 }
 
 // Classify returns the most likely classification for
 // the given piece of text.
 func (b *Bayes) Classify(text string) Sentiment {
-	features := b.features(text)
-
 	bestLogProb := math.Inf(-1)
 	var bestSentiment Sentiment
 
-	for _, sentiment := range AllSentiments {
+	logProbs := b.logProbs(text)
+	for _, sentiment := range FineSentiments {
+		logProb, ok := logProbs[sentiment]
+		if ok && logProb > bestLogProb {
+			bestLogProb = logProb
+			bestSentiment = sentiment
+		}
+	}
+
+	return bestSentiment
+}
+
+// Probabilities returns the posterior probability of
+// each sentiment given the text.
+func (b *Bayes) Probabilities(text string) map[Sentiment]float64 {
+	logProbs := b.logProbs(text)
+	maxLogProb := math.Inf(-1)
+	for _, logProb := range logProbs {
+		maxLogProb = math.Max(maxLogProb, logProb)
+	}
+	var total float64
+	res := map[Sentiment]float64{}
+	for sentiment, logProb := range logProbs {
+		res[sentiment] = math.Exp(logProb - maxLogProb)
+		total += res[sentiment]
+	}
+	for sentiment := range res {
+		res[sentiment] /= total
+	}
+	return res
+}
+
+// logProbs computes the unnormalized log-probability of
+// each sentiment which appeared in the training data.
+func (b *Bayes) logProbs(text string) map[Sentiment]float64 {
+	features := b.features(text)
+	res := map[Sentiment]float64{}
+	for _, sentiment := range FineSentiments {
 		sentProb := b.Sentiments[sentiment]
 		if sentProb == 0 {
 			continue
@@ -73,62 +139,190 @@ func (b *Bayes) Classify(text string) Sentiment {
 			}
 			logProb += math.Log(prob)
 		}
-		if logProb > bestLogProb {
-			bestLogProb = logProb
-			bestSentiment = sentiment
+		res[sentiment] = logProb
+	}
+	return res
+}
+
+// TopFeatures returns up to n features of the text whose
+// conditional probabilities most favor one sentiment over
+// another.
+func (b *Bayes) TopFeatures(text string, favored, other Sentiment, n int) []string {
+	var contributions []featureWeight
+	for feature := range b.features(text) {
+		favoredProb, ok := b.Conditional[favored][feature]
+		if !ok {
+			continue
+		}
+		otherProb, ok := b.Conditional[other][feature]
+		if !ok {
+			otherProb = b.Features[feature]
+		}
+		weight := math.Log(favoredProb) - math.Log(otherProb)
+		if weight > 0 {
+			contributions = append(contributions, featureWeight{feature, weight})
 		}
 	}
+	return topFeatures(contributions, n)
+}
 
-	return bestSentiment
+// A featureWeight is the influence of a feature on a
+// classification.
+type featureWeight struct {
+	feature string
+	weight  float64
+}
+
+// topFeatures returns up to n of the features with the
+// largest weights, from largest to smallest.
+func topFeatures(weights []featureWeight, n int) []string {
+	sort.Slice(weights, func(i, j int) bool {
+		if weights[i].weight != weights[j].weight {
+			return weights[i].weight > weights[j].weight
+		}
+		return weights[i].feature < weights[j].feature
+	})
+	var res []string
+	for i := 0; i < n && i < len(weights); i++ {
+		res = append(res, weights[i].feature)
+	}
+	return res
 }
 
 // Train regenerates the Bayes classifier using the
 // given list of samples.
+// Each sample contributes to the counts in proportion
+// to its training weight.
+//
+// Train panics if a sample has a negative weight.
 func (b *Bayes) Train(s []*Sample) {
-	b.Sentiments = map[Sentiment]float64{}
-	b.Features = map[string]float64{}
-	b.Conditional = map[Sentiment]map[string]float64{}
-	for _, sent := range AllSentiments {
-		b.Conditional[sent] = map[string]float64{}
+	if err := b.TrainContext(context.Background(), s, nil); err != nil {
+		panic(err)
+	}
+}
+
+// TrainContext is like Train, but it reports the number
+// of samples counted and can be cancelled.
+// If it is cancelled, the classifier reflects the samples
+// which were counted before cancellation.
+// It fails without training if a sample has a negative
+// weight.
+func (b *Bayes) TrainContext(ctx context.Context, s []*Sample, p ProgressFunc) error {
+	if err := checkWeights(s); err != nil {
+		return err
 	}
+	b.Counts = NewBayesCounts()
+	err := b.count(ctx, s, p)
+	b.normalize()
+	return err
+}
+
+// Update adds more samples to the counts of a trained
+// classifier, as if they had been part of the original
+// training data.
+//
+// This fails for models which were saved without their
+// raw counts (see KeepCounts).
+func (b *Bayes) Update(s []*Sample) error {
+	if b.Counts == nil {
+		return errors.New("bayes model was saved without raw counts")
+	} else if err := checkWeights(s); err != nil {
+		return err
+	}
+	b.count(context.Background(), s, nil)
+	b.normalize()
+	return nil
+}
 
+// count adds the samples to b.Counts.
+// The samples are split into one shard per CPU, each of
+// which is counted separately before the shards' counts
+// are combined.
+func (b *Bayes) count(ctx context.Context, s []*Sample, p ProgressFunc) error {
 	log.Println("Counting features...")
-	for _, sample := range s {
-		b.Sentiments[sample.Sentiment]++
-		for feature := range b.features(sample.Contents) {
-			if _, ok := b.Features[feature]; !ok {
-				b.Features[feature] = BayesSmoothing
-				for _, m := range b.Conditional {
-					m[feature] = BayesSmoothing
+	start := time.Now()
+
+	numShards := runtime.GOMAXPROCS(0)
+	shardCounts := make([]*BayesCounts, numShards)
+	var numCounted int64
+	var wg sync.WaitGroup
+	for i := range shardCounts {
+		shardCounts[i] = NewBayesCounts()
+		wg.Add(1)
+		go func(shard int) {
+			defer wg.Done()
+			counts := shardCounts[shard]
+			for j := shard; j < len(s); j += numShards {
+				if ctx.Err() != nil {
+					return
 				}
+				counts.Add(s[j], b.features(s[j].Contents))
+				atomic.AddInt64(&numCounted, 1)
 			}
-			b.Features[feature]++
-			b.Conditional[sample.Sentiment][feature]++
-		}
+		}(i)
 	}
 
-	log.Println("Pruning features...")
-	for feature, count := range b.Features {
-		if int(count-BayesSmoothing+0.5) < BayesMinFeatureCount {
-			delete(b.Features, feature)
-			for _, m := range b.Conditional {
-				delete(m, feature)
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	if p != nil {
+		ticker := time.NewTicker(progressInterval)
+	ProgressLoop:
+		for {
+			select {
+			case <-ticker.C:
+				p(TrainProgress{
+					Stage: "samples",
+					Done:  int(atomic.LoadInt64(&numCounted)),
+					Total: len(s),
+				})
+			case <-done:
+				break ProgressLoop
 			}
 		}
+		ticker.Stop()
+		p(TrainProgress{Stage: "samples", Done: int(numCounted), Total: len(s)})
 	}
+	<-done
 
-	log.Println("Normalizing", len(b.Features), "features...")
-	for sent, count := range b.Sentiments {
-		conditional := b.Conditional[sent]
-		for feature := range conditional {
-			conditional[feature] /= count
-		}
+	for _, counts := range shardCounts {
+		b.Counts.AddCounts(counts)
 	}
-	for sent, count := range b.Sentiments {
-		b.Sentiments[sent] = count / float64(len(s))
+
+	elapsed := time.Since(start)
+	log.Printf("Counted %d samples in %v (%.0f samples/sec)", numCounted,
+		elapsed, float64(numCounted)/elapsed.Seconds())
+	return ctx.Err()
+}
+
+// normalize computes the probabilities from the raw
+// counts, omitting rare features.
+func (b *Bayes) normalize() {
+	c := b.Counts
+	b.Sentiments = map[Sentiment]float64{}
+	b.Features = map[string]float64{}
+	b.Conditional = map[Sentiment]map[string]float64{}
+
+	for feature, count := range c.Features {
+		if c.Occurrences[feature] >= BayesMinFeatureCount {
+			b.Features[feature] = (count + BayesSmoothing) / c.Total
+		}
 	}
-	for feature, count := range b.Features {
-		b.Features[feature] = count / float64(len(s))
+
+	log.Println("Normalizing", len(b.Features), "features...")
+	for sent, count := range c.Sentiments {
+		if count == 0 {
+			continue
+		}
+		b.Sentiments[sent] = count / c.Total
+		counts := c.Conditional[sent]
+		conditional := map[string]float64{}
+		for feature := range b.Features {
+			conditional[feature] = (counts[feature] + BayesSmoothing) / count
+		}
+		b.Conditional[sent] = conditional
 	}
 }
 
@@ -139,16 +333,138 @@ func (b *Bayes) SerializerType() string {
 }
 
 // Serialize serializes the bayes classifier.
+// If KeepCounts is set, the probabilities are saved
+// alongside the raw counts so that the web classifier can
+// use them directly.
 func (b *Bayes) Serialize() ([]byte, error) {
-	return json.Marshal(b)
+	if b.KeepCounts {
+		return json.Marshal(b)
+	}
+	res := *b
+	res.Counts = nil
+	return json.Marshal(&res)
+}
+
+// BayesCounts stores the (weighted) number of training
+// samples with each sentiment and feature.
+// Unlike probabilities, counts from different training
+// sets can simply be added together.
+type BayesCounts struct {
+	// Total is the total weight of all samples.
+	Total float64
+
+	// Sentiments stores the total weight of the samples
+	// with each sentiment.
+	Sentiments map[Sentiment]float64
+
+	// Features stores the total weight of the samples
+	// containing each feature.
+	Features map[string]float64
+
+	// Conditional stores, for each sentiment, the total
+	// weight of the samples with that sentiment which
+	// contain each feature.
+	Conditional map[Sentiment]map[string]float64
+
+	// Occurrences stores the unweighted number of samples
+	// containing each feature, which decides whether the
+	// feature is common enough to use.
+	Occurrences map[string]int
+}
+
+// NewBayesCounts creates an empty BayesCounts.
+func NewBayesCounts() *BayesCounts {
+	return &BayesCounts{
+		Sentiments:  map[Sentiment]float64{},
+		Features:    map[string]float64{},
+		Conditional: map[Sentiment]map[string]float64{},
+		Occurrences: map[string]int{},
+	}
+}
+
+// Add adds a sample with the given features to the
+// counts.
+func (c *BayesCounts) Add(sample *Sample, features map[string]bool) {
+	weight := sample.TrainingWeight()
+	c.Total += weight
+	c.Sentiments[sample.Sentiment] += weight
+	conditional := c.Conditional[sample.Sentiment]
+	if conditional == nil {
+		conditional = map[string]float64{}
+		c.Conditional[sample.Sentiment] = conditional
+	}
+	for feature := range features {
+		c.Features[feature] += weight
+		conditional[feature] += weight
+		c.Occurrences[feature]++
+	}
+}
+
+// AddCounts adds all of the counts from c1 to c.
+func (c *BayesCounts) AddCounts(c1 *BayesCounts) {
+	c.Total += c1.Total
+	for sent, count := range c1.Sentiments {
+		c.Sentiments[sent] += count
+	}
+	for feature, count := range c1.Features {
+		c.Features[feature] += count
+	}
+	for sent, counts := range c1.Conditional {
+		conditional := c.Conditional[sent]
+		if conditional == nil {
+			conditional = map[string]float64{}
+			c.Conditional[sent] = conditional
+		}
+		for feature, count := range counts {
+			conditional[feature] += count
+		}
+	}
+	for feature, count := range c1.Occurrences {
+		c.Occurrences[feature] += count
+	}
+}
+
+// MergeBayes combines Bayes models which were trained on
+// different sets of samples into a single model, which is
+// equivalent to a model trained on all of the samples.
+//
+// The models must all have raw counts and must agree on
+// whether or not to use bigraphs.
+// The merged model keeps its counts, so that it can be
+// merged again.
+func MergeBayes(models ...*Bayes) (*Bayes, error) {
+	if len(models) == 0 {
+		return nil, errors.New("no models to merge")
+	}
+	res := &Bayes{
+		Bigraph:    models[0].Bigraph,
+		Counts:     NewBayesCounts(),
+		KeepCounts: true,
+	}
+	for i, model := range models {
+		if model.Counts == nil {
+			return nil, fmt.Errorf("model %d has no raw counts", i)
+		} else if model.Bigraph != res.Bigraph {
+			return nil, fmt.Errorf("model %d has different features", i)
+		}
+		res.Counts.AddCounts(model.Counts)
+	}
+	res.normalize()
+	return res, nil
 }
 
 func (b *Bayes) features(text string) map[string]bool {
+	return bayesFeatures(text, b.Bigraph)
+}
+
+// bayesFeatures returns the set of unigraphs (and,
+// optionally, bigraphs) in a piece of text.
+func bayesFeatures(text string, bigraph bool) map[string]bool {
 	fields := strings.Fields(SeparatePunctuation(Normalize(text)))
 	res := map[string]bool{}
 	for i, f := range fields {
 		res[f] = true
-		if i > 0 && b.Bigraph {
+		if i > 0 && bigraph {
 			res[fields[i-1]+" "+f] = true
 		}
 	}
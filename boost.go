@@ -0,0 +1,497 @@
+package sentigraph
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"math"
+	"math/rand"
+	"sort"
+	"sync"
+
+	"github.com/unixpickle/serializer"
+)
+
+// These environment variables override the corresponding
+// Boost hyperparameters during training.
+const (
+	BoostRoundsEnvVar       = "BOOST_ROUNDS"
+	BoostLearningRateEnvVar = "BOOST_LEARNING_RATE"
+	BoostDepthEnvVar        = "BOOST_DEPTH"
+)
+
+// Default Boost hyperparameters, used when the
+// corresponding fields are 0.
+const (
+	BoostDefaultRounds       = 100
+	BoostDefaultLearningRate = 0.3
+	BoostDefaultDepth        = 4
+	BoostDefaultMinLeaf      = 5
+	BoostDefaultSubsample    = 0.5
+)
+
+// BoostMinFeatureCount is the minimum number of training
+// samples a feature must appear in to be used.
+const BoostMinFeatureCount = 5
+
+// boostRegularization is the L2 penalty on leaf values.
+const boostRegularization = 1
+
+func init() {
+	var b Boost
+	serializer.RegisterTypedDeserializer(b.SerializerType(), DeserializeBoost)
+}
+
+// Boost classifies text using gradient-boosted decision
+// trees trained with a softmax loss.
+// It uses the same boolean word features as Forest, but
+// its shallow trees make for a much smaller model.
+type Boost struct {
+	// Bigraph is true if bigraphs should be used in
+	// addition to unigraphs.
+	Bigraph bool
+
+	// Rounds is the number of boosting rounds.
+	// In each round, one tree is added per class.
+	Rounds int
+
+	// LearningRate scales the output of each tree.
+	LearningRate float64
+
+	// Depth is the maximum depth of each tree.
+	Depth int
+
+	// MinLeaf is the minimum number of samples in a leaf.
+	MinLeaf int
+
+	// Subsample is the fraction of the samples used to
+	// build each round's trees.
+	Subsample float64
+
+	// Seed seeds the random number generator used for
+	// subsampling.
+	// If it is 0 when training begins, a seed is chosen
+	// at random and stored here.
+	Seed int64
+
+	// Classes lists the sentiments in the training data.
+	// The i-th score in Prior and in each round of Trees
+	// corresponds to Classes[i].
+	Classes []Sentiment
+
+	// Prior is the initial score for each class.
+	Prior []float64
+
+	// Trees stores, for each round, one tree per class.
+	Trees [][]*BoostNode
+}
+
+// A BoostNode is a node in a regression tree.
+// Leaf nodes have no Feature.
+type BoostNode struct {
+	Feature string     `json:"a,omitempty"`
+	Value   float64    `json:"v,omitempty"`
+	True    *BoostNode `json:"t,omitempty"`
+	False   *BoostNode `json:"f,omitempty"`
+}
+
+// Eval returns the leaf value for the feature set.
+func (b *BoostNode) Eval(features map[string]bool) float64 {
+	for b.Feature != "" {
+		if features[b.Feature] {
+			b = b.True
+		} else {
+			b = b.False
+		}
+	}
+	return b.Value
+}
+
+// DeserializeBoost deserializes a Boost model.
+func DeserializeBoost(d []byte) (*Boost, error) {
+	var res Boost
+	if err := json.Unmarshal(d, &res); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+// Classify returns the most likely sentiment.
+func (b *Boost) Classify(text string) Sentiment {
+	best, _ := Confidence(b.Probabilities(text))
+	return best
+}
+
+// Probabilities returns the softmax of the class scores.
+func (b *Boost) Probabilities(text string) map[Sentiment]float64 {
+	features := newForestSampleText(b.Bigraph, text).features
+	scores := append([]float64{}, b.Prior...)
+	for _, round := range b.Trees {
+		for i, tree := range round {
+			scores[i] += b.LearningRate * tree.Eval(features)
+		}
+	}
+	probs := softmax(scores)
+	res := map[Sentiment]float64{}
+	for i, class := range b.Classes {
+		res[class] = probs[i]
+	}
+	return res
+}
+
+// TopFeatures returns up to n features of the text which
+// most favor one sentiment over another.
+// A feature's influence is how much the difference
+// between the two sentiments' scores would shrink if the
+// feature were removed from the text.
+func (b *Boost) TopFeatures(text string, favored, other Sentiment, n int) []string {
+	favoredIdx, otherIdx := -1, -1
+	for i, class := range b.Classes {
+		if class == favored {
+			favoredIdx = i
+		} else if class == other {
+			otherIdx = i
+		}
+	}
+	if favoredIdx < 0 || otherIdx < 0 {
+		return nil
+	}
+
+	features := newForestSampleText(b.Bigraph, text).features
+	scoreDiff := func() float64 {
+		var res float64
+		for _, round := range b.Trees {
+			res += b.LearningRate * (round[favoredIdx].Eval(features) -
+				round[otherIdx].Eval(features))
+		}
+		return res
+	}
+	baseDiff := scoreDiff()
+
+	// The features are removed one at a time, so they are
+	// copied to avoid modifying the map while ranging over
+	// it.
+	present := make([]string, 0, len(features))
+	for feature := range features {
+		present = append(present, feature)
+	}
+	var contributions []featureWeight
+	for _, feature := range present {
+		delete(features, feature)
+		weight := baseDiff - scoreDiff()
+		features[feature] = true
+		if weight > 0 {
+			contributions = append(contributions, featureWeight{feature, weight})
+		}
+	}
+	return topFeatures(contributions, n)
+}
+
+// Train trains the model on the samples.
+func (b *Boost) Train(s []*Sample) {
+	b.TrainContext(context.Background(), s, nil)
+}
+
+// TrainContext is like Train, but it reports the number
+// of rounds and the training loss, and it can be
+// cancelled.
+// If it is cancelled, the model contains the rounds which
+// were finished before cancellation.
+func (b *Boost) TrainContext(ctx context.Context, s []*Sample, p ProgressFunc) error {
+	b.setDefaults()
+	envInt(BoostRoundsEnvVar, &b.Rounds)
+	envFloat(BoostLearningRateEnvVar, &b.LearningRate)
+	envInt(BoostDepthEnvVar, &b.Depth)
+	if b.Seed == 0 {
+		b.Seed = NewSeed()
+	}
+	r := rand.New(rand.NewSource(b.Seed))
+
+	log.Println("Creating samples...")
+	data := newBoostData(s, b.Bigraph)
+	log.Println("Created", len(s), "samples with", len(data.features), "features")
+
+	b.Classes = data.classes
+	b.Prior = make([]float64, len(b.Classes))
+	var total float64
+	for _, w := range data.weights {
+		total += w
+	}
+	for i, label := range data.labels {
+		b.Prior[label] += data.weights[i] / total
+	}
+	for i, prior := range b.Prior {
+		b.Prior[i] = math.Log(prior)
+	}
+
+	scores := make([][]float64, len(s))
+	for i := range scores {
+		scores[i] = append([]float64{}, b.Prior...)
+	}
+
+	b.Trees = nil
+	for round := 0; round < b.Rounds; round++ {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		probs := make([][]float64, len(s))
+		var loss float64
+		for i, sampleScores := range scores {
+			probs[i] = softmax(sampleScores)
+			loss -= data.weights[i] * math.Log(probs[i][data.labels[i]]) / total
+		}
+
+		rows := data.subsample(b.Subsample, r)
+		trees := make([]*BoostNode, len(b.Classes))
+		var wg sync.WaitGroup
+		for class := range trees {
+			wg.Add(1)
+			go func(class int) {
+				defer wg.Done()
+				trees[class] = data.buildTree(rows, probs, class, b.Depth, b.MinLeaf)
+			}(class)
+		}
+		wg.Wait()
+
+		for i, sample := range data.sampleFeatures {
+			for class, tree := range trees {
+				scores[i][class] += b.LearningRate * tree.Eval(sample)
+			}
+		}
+		b.Trees = append(b.Trees, trees)
+		if p != nil {
+			p(TrainProgress{Stage: "rounds", Done: round + 1, Total: b.Rounds, Loss: loss})
+		}
+	}
+	return nil
+}
+
+// SetSeed sets b.Seed.
+func (b *Boost) SetSeed(seed int64) {
+	b.Seed = seed
+}
+
+// SerializerType gives the unique ID used to serialize
+// Boost models with the serializer package.
+func (b *Boost) SerializerType() string {
+	return "github.com/unixpickle/sentigraph.Boost"
+}
+
+// Serialize serializes the model.
+func (b *Boost) Serialize() ([]byte, error) {
+	return json.Marshal(b)
+}
+
+func (b *Boost) setDefaults() {
+	if b.Rounds == 0 {
+		b.Rounds = BoostDefaultRounds
+	}
+	if b.LearningRate == 0 {
+		b.LearningRate = BoostDefaultLearningRate
+	}
+	if b.Depth == 0 {
+		b.Depth = BoostDefaultDepth
+	}
+	if b.MinLeaf == 0 {
+		b.MinLeaf = BoostDefaultMinLeaf
+	}
+	if b.Subsample == 0 {
+		b.Subsample = BoostDefaultSubsample
+	}
+}
+
+// boostData is a compact representation of the training
+// samples, in which features are numbered.
+type boostData struct {
+	classes  []Sentiment
+	features []string
+
+	// Each sample's feature IDs, and its feature set (for
+	// evaluating trees).
+	sampleIDs      [][]int
+	sampleFeatures []map[string]bool
+
+	labels  []int
+	weights []float64
+}
+
+func newBoostData(s []*Sample, bigraph bool) *boostData {
+	res := &boostData{
+		sampleIDs:      make([][]int, len(s)),
+		sampleFeatures: make([]map[string]bool, len(s)),
+		labels:         make([]int, len(s)),
+		weights:        make([]float64, len(s)),
+	}
+
+	classIndices := map[Sentiment]int{}
+	for _, sent := range FineSentiments {
+		for _, sample := range s {
+			if sample.Sentiment == sent {
+				classIndices[sent] = len(res.classes)
+				res.classes = append(res.classes, sent)
+				break
+			}
+		}
+	}
+
+	counts := map[string]int{}
+	for i, sample := range s {
+		res.sampleFeatures[i] = newForestSampleText(bigraph, sample.Contents).features
+		for feature := range res.sampleFeatures[i] {
+			counts[feature]++
+		}
+		res.labels[i] = classIndices[sample.Sentiment]
+		res.weights[i] = sample.TrainingWeight()
+	}
+
+	for feature, count := range counts {
+		if count >= BoostMinFeatureCount {
+			res.features = append(res.features, feature)
+		}
+	}
+	sort.Strings(res.features)
+	ids := map[string]int{}
+	for i, feature := range res.features {
+		ids[feature] = i
+	}
+	for i, features := range res.sampleFeatures {
+		for feature := range features {
+			if id, ok := ids[feature]; ok {
+				res.sampleIDs[i] = append(res.sampleIDs[i], id)
+			}
+		}
+		sort.Ints(res.sampleIDs[i])
+	}
+	return res
+}
+
+// subsample randomly chooses a fraction of the sample
+// indices, without replacement.
+func (b *boostData) subsample(frac float64, r *rand.Rand) []int {
+	perm := r.Perm(len(b.labels))
+	count := int(frac * float64(len(perm)))
+	if count < 1 || frac >= 1 {
+		count = len(perm)
+	}
+	res := perm[:count]
+	sort.Ints(res)
+	return res
+}
+
+// buildTree fits a regression tree to the gradient of the
+// softmax loss for one class, using a second-order
+// (Newton) approximation of the loss.
+func (b *boostData) buildTree(rows []int, probs [][]float64, class,
+	depth, minLeaf int) *BoostNode {
+	grads := make([]float64, len(b.labels))
+	hessians := make([]float64, len(b.labels))
+	for _, i := range rows {
+		target := 0.0
+		if b.labels[i] == class {
+			target = 1
+		}
+		p := probs[i][class]
+		grads[i] = b.weights[i] * (target - p)
+		hessians[i] = b.weights[i] * math.Max(p*(1-p), 1e-6)
+	}
+	builder := &boostTreeBuilder{
+		data:     b,
+		grads:    grads,
+		hessians: hessians,
+		minLeaf:  minLeaf,
+		sumG:     make([]float64, len(b.features)),
+		sumH:     make([]float64, len(b.features)),
+		counts:   make([]int, len(b.features)),
+	}
+	return builder.build(rows, depth)
+}
+
+type boostTreeBuilder struct {
+	data     *boostData
+	grads    []float64
+	hessians []float64
+	minLeaf  int
+
+	// Scratch space, indexed by feature ID.
+	sumG   []float64
+	sumH   []float64
+	counts []int
+}
+
+func (b *boostTreeBuilder) build(rows []int, depth int) *BoostNode {
+	var totalG, totalH float64
+	for _, i := range rows {
+		totalG += b.grads[i]
+		totalH += b.hessians[i]
+	}
+	leaf := &BoostNode{Value: totalG / (totalH + boostRegularization)}
+	if depth == 0 || len(rows) < 2*b.minLeaf {
+		return leaf
+	}
+
+	var touched []int
+	for _, i := range rows {
+		for _, id := range b.data.sampleIDs[i] {
+			if b.counts[id] == 0 {
+				touched = append(touched, id)
+			}
+			b.sumG[id] += b.grads[i]
+			b.sumH[id] += b.hessians[i]
+			b.counts[id]++
+		}
+	}
+	sort.Ints(touched)
+
+	baseScore := totalG * totalG / (totalH + boostRegularization)
+	bestGain := 0.0
+	bestFeature := -1
+	for _, id := range touched {
+		numTrue := b.counts[id]
+		if numTrue >= b.minLeaf && len(rows)-numTrue >= b.minLeaf {
+			g, h := b.sumG[id], b.sumH[id]
+			gain := g*g/(h+boostRegularization) +
+				(totalG-g)*(totalG-g)/(totalH-h+boostRegularization) - baseScore
+			if gain > bestGain {
+				bestGain = gain
+				bestFeature = id
+			}
+		}
+		b.sumG[id], b.sumH[id], b.counts[id] = 0, 0, 0
+	}
+	if bestFeature < 0 {
+		return leaf
+	}
+
+	var trueRows, falseRows []int
+	for _, i := range rows {
+		ids := b.data.sampleIDs[i]
+		idx := sort.SearchInts(ids, bestFeature)
+		if idx < len(ids) && ids[idx] == bestFeature {
+			trueRows = append(trueRows, i)
+		} else {
+			falseRows = append(falseRows, i)
+		}
+	}
+	return &BoostNode{
+		Feature: b.data.features[bestFeature],
+		True:    b.build(trueRows, depth-1),
+		False:   b.build(falseRows, depth-1),
+	}
+}
+
+func softmax(scores []float64) []float64 {
+	maxScore := math.Inf(-1)
+	for _, s := range scores {
+		maxScore = math.Max(maxScore, s)
+	}
+	res := make([]float64, len(scores))
+	var sum float64
+	for i, s := range scores {
+		res[i] = math.Exp(s - maxScore)
+		sum += res[i]
+	}
+	for i := range res {
+		res[i] /= sum
+	}
+	return res
+}
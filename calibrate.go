@@ -0,0 +1,308 @@
+package sentigraph
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"sort"
+
+	"github.com/unixpickle/serializer"
+)
+
+func init() {
+	var c Calibrated
+	serializer.RegisterTypedDeserializer(c.SerializerType(), DeserializeCalibrated)
+}
+
+// Calibrated wraps a model so that its probabilities are
+// calibrated: of the texts which it gives a probability
+// of 0.8 for a sentiment, about 80% should actually have
+// that sentiment.
+//
+// Each sentiment's probability is calibrated separately,
+// and the results are normalized to sum to 1.
+// The calibration is fit on samples which the wrapped
+// model was not trained on.
+type Calibrated struct {
+	Model ProbabilityModel
+
+	// Isotonic is true if the calibration uses isotonic
+	// regression rather than Platt scaling.
+	Isotonic bool
+
+	Classes map[Sentiment]*ClassCalibration
+}
+
+// A ClassCalibration maps a model's probability for one
+// sentiment to a calibrated probability.
+type ClassCalibration struct {
+	// A and B are the parameters of Platt scaling, which
+	// computes sigmoid(A*logit(p) + B).
+	A float64 `json:",omitempty"`
+	B float64 `json:",omitempty"`
+
+	// X and Y are the points of the piecewise linear
+	// function found by isotonic regression.
+	X []float64 `json:",omitempty"`
+	Y []float64 `json:",omitempty"`
+}
+
+// DeserializeCalibrated deserializes a Calibrated model.
+func DeserializeCalibrated(d []byte) (*Calibrated, error) {
+	slice, err := serializer.DeserializeSlice(d)
+	if err != nil {
+		return nil, err
+	}
+	if len(slice) != 2 {
+		return nil, errors.New("invalid Calibrated slice")
+	}
+	model, ok := slice[0].(ProbabilityModel)
+	data, ok1 := slice[1].(serializer.Bytes)
+	if !ok || !ok1 {
+		return nil, errors.New("invalid Calibrated slice")
+	}
+	res := &Calibrated{Model: model}
+	if err := json.Unmarshal(data, res); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// Fit fits the calibration to the model's probabilities
+// for a set of held-out samples and their actual
+// sentiments.
+func (c *Calibrated) Fit(probs []map[Sentiment]float64, actual []Sentiment) {
+	c.Classes = map[Sentiment]*ClassCalibration{}
+	for _, class := range FineSentiments {
+		var xs, ys []float64
+		var found bool
+		for i, p := range probs {
+			if _, ok := p[class]; !ok {
+				continue
+			}
+			found = true
+			xs = append(xs, p[class])
+			if actual[i] == class {
+				ys = append(ys, 1)
+			} else {
+				ys = append(ys, 0)
+			}
+		}
+		if !found {
+			continue
+		}
+		if c.Isotonic {
+			c.Classes[class] = fitIsotonic(xs, ys)
+		} else {
+			c.Classes[class] = fitPlatt(xs, ys)
+		}
+	}
+}
+
+// Classify returns the sentiment with the highest
+// calibrated probability.
+func (c *Calibrated) Classify(text string) Sentiment {
+	best, _ := Confidence(c.Probabilities(text))
+	return best
+}
+
+// Probabilities returns the calibrated probabilities.
+func (c *Calibrated) Probabilities(text string) map[Sentiment]float64 {
+	probs := c.Model.Probabilities(text)
+	res := map[Sentiment]float64{}
+	var total float64
+	for class, prob := range probs {
+		if cal, ok := c.Classes[class]; ok {
+			prob = cal.Apply(prob)
+		}
+		res[class] = prob
+		total += prob
+	}
+	if total == 0 {
+		return probs
+	}
+	for class := range res {
+		res[class] /= total
+	}
+	return res
+}
+
+// TopFeatures returns the wrapped model's top features,
+// or nil if the wrapped model is not a FeatureModel.
+func (c *Calibrated) TopFeatures(text string, favored, other Sentiment, n int) []string {
+	if featureModel, ok := c.Model.(FeatureModel); ok {
+		return featureModel.TopFeatures(text, favored, other, n)
+	}
+	return nil
+}
+
+// Train trains the wrapped model.
+// The calibration is not changed, so it should be fit
+// again afterwards.
+func (c *Calibrated) Train(s []*Sample) {
+	c.Model.Train(s)
+}
+
+// SerializerType gives the unique ID used to serialize
+// Calibrated models with the serializer package.
+func (c *Calibrated) SerializerType() string {
+	return "github.com/unixpickle/sentigraph.Calibrated"
+}
+
+// Serialize serializes the wrapped model and the
+// calibration.
+func (c *Calibrated) Serialize() ([]byte, error) {
+	data, err := json.Marshal(c)
+	if err != nil {
+		return nil, err
+	}
+	return serializer.SerializeSlice([]serializer.Serializer{
+		c.Model,
+		serializer.Bytes(data),
+	})
+}
+
+// MarshalJSON encodes the calibration without the wrapped
+// model, which is serialized separately.
+func (c *Calibrated) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Isotonic bool
+		Classes  map[Sentiment]*ClassCalibration
+	}{c.Isotonic, c.Classes})
+}
+
+// Apply calibrates a probability.
+func (c *ClassCalibration) Apply(p float64) float64 {
+	if c.X == nil {
+		return sigmoid(c.A*logit(p) + c.B)
+	}
+	idx := sort.SearchFloat64s(c.X, p)
+	if idx == 0 {
+		return c.Y[0]
+	} else if idx == len(c.X) {
+		return c.Y[len(c.Y)-1]
+	}
+	frac := (p - c.X[idx-1]) / (c.X[idx] - c.X[idx-1])
+	return c.Y[idx-1] + frac*(c.Y[idx]-c.Y[idx-1])
+}
+
+// fitPlatt fits Platt scaling with Newton's method,
+// using Platt's smoothed targets to avoid overfitting.
+func fitPlatt(xs, ys []float64) *ClassCalibration {
+	var numPos, numNeg float64
+	for _, y := range ys {
+		if y == 1 {
+			numPos++
+		} else {
+			numNeg++
+		}
+	}
+	targets := make([]float64, len(ys))
+	logits := make([]float64, len(xs))
+	for i, y := range ys {
+		if y == 1 {
+			targets[i] = (numPos + 1) / (numPos + 2)
+		} else {
+			targets[i] = 1 / (numNeg + 2)
+		}
+		logits[i] = logit(xs[i])
+	}
+
+	loss := func(a, b float64) float64 {
+		var res float64
+		for i, x := range logits {
+			z := a*x + b
+			// Cross-entropy, computed stably from the logit.
+			res += math.Max(z, 0) - targets[i]*z + math.Log1p(math.Exp(-math.Abs(z)))
+		}
+		return res
+	}
+
+	a, b := 1.0, 0.0
+	curLoss := loss(a, b)
+	for iter := 0; iter < 100; iter++ {
+		var ga, gb, haa, hab, hbb float64
+		for i, x := range logits {
+			p := sigmoid(a*x + b)
+			d := p - targets[i]
+			ga += d * x
+			gb += d
+			w := math.Max(p*(1-p), 1e-12)
+			haa += w * x * x
+			hab += w * x
+			hbb += w
+		}
+		haa += 1e-9
+		hbb += 1e-9
+		det := haa*hbb - hab*hab
+		if det == 0 {
+			break
+		}
+		stepA := (hbb*ga - hab*gb) / det
+		stepB := (haa*gb - hab*ga) / det
+
+		// Halve the step until the loss decreases.
+		improved := false
+		for scale := 1.0; scale > 1e-10; scale /= 2 {
+			newA, newB := a-scale*stepA, b-scale*stepB
+			if newLoss := loss(newA, newB); newLoss < curLoss {
+				improved = curLoss-newLoss > 1e-10*math.Abs(curLoss)
+				a, b, curLoss = newA, newB, newLoss
+				break
+			}
+		}
+		if !improved {
+			break
+		}
+	}
+	return &ClassCalibration{A: a, B: b}
+}
+
+// fitIsotonic fits a non-decreasing function with the
+// pool adjacent violators algorithm.
+func fitIsotonic(xs, ys []float64) *ClassCalibration {
+	order := make([]int, len(xs))
+	for i := range order {
+		order[i] = i
+	}
+	sort.Slice(order, func(i, j int) bool {
+		return xs[order[i]] < xs[order[j]]
+	})
+
+	type block struct {
+		sumX, sumY, count float64
+	}
+	var blocks []block
+	for _, idx := range order {
+		blocks = append(blocks, block{sumX: xs[idx], sumY: ys[idx], count: 1})
+		for len(blocks) > 1 {
+			last := blocks[len(blocks)-1]
+			prev := blocks[len(blocks)-2]
+			if prev.sumY/prev.count < last.sumY/last.count {
+				break
+			}
+			blocks = blocks[:len(blocks)-1]
+			blocks[len(blocks)-1] = block{
+				sumX:  prev.sumX + last.sumX,
+				sumY:  prev.sumY + last.sumY,
+				count: prev.count + last.count,
+			}
+		}
+	}
+
+	res := &ClassCalibration{}
+	for _, b := range blocks {
+		res.X = append(res.X, b.sumX/b.count)
+		res.Y = append(res.Y, b.sumY/b.count)
+	}
+	return res
+}
+
+func sigmoid(x float64) float64 {
+	return 1 / (1 + math.Exp(-x))
+}
+
+func logit(p float64) float64 {
+	p = math.Max(1e-6, math.Min(1-1e-6, p))
+	return math.Log(p / (1 - p))
+}
@@ -0,0 +1,140 @@
+// Command calibrate wraps a model so that its
+// probabilities are calibrated, fitting the calibration
+// on a held-out corpus.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"os"
+
+	"github.com/unixpickle/sentigraph"
+	"github.com/unixpickle/serializer"
+)
+
+const (
+	ModelArg  = 0
+	CorpusArg = 1
+	OutputArg = 2
+)
+
+// ReliabilityBins is the number of bins used to measure
+// the calibration error.
+const ReliabilityBins = 10
+
+func main() {
+	var method string
+	var holdout float64
+	var seed int64
+	flag.StringVar(&method, "method", "platt",
+		"calibration method (platt or isotonic)")
+	flag.Float64Var(&holdout, "holdout", 0.2,
+		"fraction of the corpus to measure the calibration error on, "+
+			"instead of fitting to it")
+	flag.Int64Var(&seed, "seed", 0, "random seed for the holdout split (0 for random)")
+	flag.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage:", os.Args[0],
+			"[flags] model_file heldout.csv output_model")
+		fmt.Fprintln(os.Stderr, "\nFlags:")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if flag.NArg() != 3 {
+		flag.Usage()
+		os.Exit(1)
+	}
+	if method != "platt" && method != "isotonic" {
+		fmt.Fprintln(os.Stderr, "Unknown calibration method:", method)
+		os.Exit(1)
+	}
+	if holdout < 0 || holdout >= 1 {
+		fmt.Fprintln(os.Stderr, "Holdout fraction must be in [0, 1).")
+		os.Exit(1)
+	}
+	if seed == 0 {
+		seed = sentigraph.NewSeed()
+	}
+
+	model, err := sentigraph.ReadModel(flag.Arg(ModelArg))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Failed to read model:", err)
+		os.Exit(1)
+	}
+	if calibrated, ok := model.(*sentigraph.Calibrated); ok {
+		model = calibrated.Model
+	}
+	probModel, ok := model.(sentigraph.ProbabilityModel)
+	if !ok {
+		fmt.Fprintf(os.Stderr, "Model does not produce probabilities: %T\n", model)
+		os.Exit(1)
+	}
+
+	samples := readSamples()
+	r := rand.New(rand.NewSource(seed))
+	r.Shuffle(len(samples), func(i, j int) {
+		samples[i], samples[j] = samples[j], samples[i]
+	})
+	numHeldOut := int(holdout * float64(len(samples)))
+	fitSamples, heldOut := samples[numHeldOut:], samples[:numHeldOut]
+	if len(fitSamples) == 0 {
+		fmt.Fprintln(os.Stderr, "No samples left to fit the calibration.")
+		os.Exit(1)
+	}
+
+	calibrated := &sentigraph.Calibrated{Model: probModel, Isotonic: method == "isotonic"}
+	calibrated.Fit(sentigraph.BatchProbabilities(probModel, fitSamples),
+		sampleSentiments(fitSamples))
+
+	if len(heldOut) == 0 {
+		fmt.Println("No held-out samples to measure the calibration error on.")
+	} else {
+		fmt.Println("Measuring calibration on", len(heldOut), "held-out samples.")
+		fmt.Printf("Calibration error before: %.4f\n", calibrationError(probModel, heldOut))
+		fmt.Printf("Calibration error after: %.4f\n", calibrationError(calibrated, heldOut))
+	}
+
+	data, err := serializer.SerializeWithType(calibrated)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Failed to serialize model:", err)
+		os.Exit(1)
+	}
+	if err := ioutil.WriteFile(flag.Arg(OutputArg), data, 0755); err != nil {
+		fmt.Fprintln(os.Stderr, "Failed to write model file:", err)
+		os.Exit(1)
+	}
+}
+
+func readSamples() []*sentigraph.Sample {
+	corpusFile, err := os.Open(flag.Arg(CorpusArg))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Failed to open corpus:", err)
+		os.Exit(1)
+	}
+	defer corpusFile.Close()
+	samples, err := sentigraph.ReadSamples(corpusFile)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Failed to parse corpus:", err)
+		os.Exit(1)
+	}
+	return samples
+}
+
+// calibrationError computes the expected calibration
+// error of the model on the samples.
+func calibrationError(model sentigraph.ProbabilityModel,
+	samples []*sentigraph.Sample) float64 {
+	probs := sentigraph.BatchProbabilities(model, samples)
+	bins := sentigraph.Reliability(probs, sampleSentiments(samples), ReliabilityBins)
+	return sentigraph.CalibrationError(bins)
+}
+
+func sampleSentiments(samples []*sentigraph.Sample) []sentigraph.Sentiment {
+	res := make([]sentigraph.Sentiment, len(samples))
+	for i, sample := range samples {
+		res[i] = sample.Sentiment
+	}
+	return res
+}
@@ -0,0 +1,125 @@
+// Command compare evaluates several models on the same
+// corpus and tests whether the differences between their
+// accuracies are significant.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"math/rand"
+	"os"
+	"runtime"
+	"sync"
+
+	"github.com/unixpickle/sentigraph"
+)
+
+func main() {
+	var corpusPath string
+	var rounds int
+	var seed int64
+	flag.StringVar(&corpusPath, "corpus", "", "corpus to evaluate the models on (required)")
+	flag.IntVar(&rounds, "bootstrap", 1000,
+		"number of bootstrap samples for the confidence intervals (0 for none)")
+	flag.Int64Var(&seed, "seed", 0, "random seed for bootstrapping (0 for random)")
+	flag.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage:", os.Args[0],
+			"[flags] -corpus corpus.csv model_file1 model_file2 ...")
+		fmt.Fprintln(os.Stderr, "\nFlags:")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if flag.NArg() < 2 || corpusPath == "" {
+		flag.Usage()
+		os.Exit(1)
+	}
+	if rounds < 0 {
+		fmt.Fprintln(os.Stderr, "Number of bootstrap samples must not be negative.")
+		os.Exit(1)
+	}
+	if seed == 0 {
+		seed = sentigraph.NewSeed()
+	}
+
+	samples := readSamples(corpusPath)
+	if len(samples) == 0 {
+		fmt.Fprintln(os.Stderr, "Corpus is empty.")
+		os.Exit(1)
+	}
+	paths := flag.Args()
+	evaluations := make([]*sentigraph.Evaluation, len(paths))
+	correct := make([][]bool, len(paths))
+	for i, path := range paths {
+		log.Println("Evaluating", path, "...")
+		evaluations[i], correct[i] = evaluate(path, samples)
+	}
+
+	fmt.Printf("\n%-30s %10s %10s %10s\n", "model", "accuracy", "macro-F1", "kappa")
+	for i, path := range paths {
+		e := evaluations[i]
+		fmt.Printf("%-30s %9.2f%% %10.4f %10.4f\n", path, e.Accuracy*100,
+			e.MacroF1, e.Kappa)
+	}
+
+	fmt.Println("\naccuracy differences (with 95% bootstrap confidence intervals):")
+	r := rand.New(rand.NewSource(seed))
+	for i := range paths {
+		for j := i + 1; j < len(paths); j++ {
+			diff, low, high := bootstrapDifference(correct[i], correct[j], rounds, r)
+			only1, only2, p := mcNemar(correct[i], correct[j])
+			fmt.Printf("%s vs %s: %+.2f%% [%+.2f%%, %+.2f%%], McNemar p = %.4g"+
+				" (%d vs %d discordant)\n", paths[i], paths[j], diff*100, low*100,
+				high*100, p, only1, only2)
+		}
+	}
+}
+
+// evaluate runs a model on every sample, returning the
+// evaluation and whether each sample was classified
+// correctly.
+func evaluate(path string, samples []*sentigraph.Sample) (*sentigraph.Evaluation, []bool) {
+	model, err := sentigraph.ReadModel(path)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Failed to read model:", err)
+		os.Exit(1)
+	}
+
+	predictions := make([]sentigraph.Sentiment, len(samples))
+	numProcs := runtime.GOMAXPROCS(0)
+	var wg sync.WaitGroup
+	for i := 0; i < numProcs; i++ {
+		wg.Add(1)
+		go func(start int) {
+			defer wg.Done()
+			for j := start; j < len(samples); j += numProcs {
+				predictions[j] = model.Classify(samples[j].Contents)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	confusion := sentigraph.Confusion{}
+	correct := make([]bool, len(samples))
+	for i, sample := range samples {
+		confusion.Add(sample.Sentiment, predictions[i])
+		correct[i] = predictions[i] == sample.Sentiment
+	}
+	return sentigraph.Evaluate(confusion), correct
+}
+
+func readSamples(path string) []*sentigraph.Sample {
+	corpusFile, err := os.Open(path)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Failed to open corpus:", err)
+		os.Exit(1)
+	}
+	defer corpusFile.Close()
+	samples, err := sentigraph.ReadSamples(corpusFile)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Failed to parse corpus:", err)
+		os.Exit(1)
+	}
+	return samples
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+)
+
+// ExactMcNemarLimit is the number of discordant samples
+// below which McNemar's test uses the exact binomial
+// distribution instead of the chi-squared approximation.
+const ExactMcNemarLimit = 25
+
+// mcNemar runs McNemar's test on the paired outcomes of
+// two models.
+// It returns the number of samples which only the first
+// model got right, the number which only the second got
+// right, and the two-sided p-value for the hypothesis
+// that the models are equally accurate.
+func mcNemar(correct1, correct2 []bool) (int, int, float64) {
+	var only1, only2 int
+	for i, c := range correct1 {
+		if c && !correct2[i] {
+			only1++
+		} else if !c && correct2[i] {
+			only2++
+		}
+	}
+	n := only1 + only2
+	if n == 0 {
+		return only1, only2, 1
+	}
+	if n < ExactMcNemarLimit {
+		k := only1
+		if only2 < k {
+			k = only2
+		}
+		var tail float64
+		for i := 0; i <= k; i++ {
+			tail += math.Exp(logChoose(n, i) - float64(n)*math.Ln2)
+		}
+		return only1, only2, math.Min(1, 2*tail)
+	}
+	// Chi-squared with one degree of freedom and a
+	// continuity correction.
+	diff := math.Abs(float64(only1-only2)) - 1
+	chi2 := math.Max(diff, 0) * math.Max(diff, 0) / float64(n)
+	return only1, only2, math.Erfc(math.Sqrt(chi2 / 2))
+}
+
+// bootstrapDifference estimates a 95% confidence interval
+// for the difference between the accuracies of two models
+// by resampling the corpus.
+// It returns the observed difference and the bounds of
+// the interval.
+func bootstrapDifference(correct1, correct2 []bool, rounds int,
+	r *rand.Rand) (float64, float64, float64) {
+	difference := func(indices func(int) int) float64 {
+		var sum float64
+		for i := range correct1 {
+			idx := indices(i)
+			if correct1[idx] {
+				sum++
+			}
+			if correct2[idx] {
+				sum--
+			}
+		}
+		return sum / float64(len(correct1))
+	}
+	observed := difference(func(i int) int { return i })
+	if rounds == 0 || len(correct1) == 0 {
+		return observed, observed, observed
+	}
+
+	diffs := make([]float64, rounds)
+	for i := range diffs {
+		diffs[i] = difference(func(int) int { return r.Intn(len(correct1)) })
+	}
+	sort.Float64s(diffs)
+	low := diffs[int(0.025*float64(rounds-1)+0.5)]
+	high := diffs[int(0.975*float64(rounds-1)+0.5)]
+	return observed, low, high
+}
+
+func logChoose(n, k int) float64 {
+	a, _ := math.Lgamma(float64(n + 1))
+	b, _ := math.Lgamma(float64(k + 1))
+	c, _ := math.Lgamma(float64(n - k + 1))
+	return a - b - c
+}
@@ -0,0 +1,83 @@
+package sentigraph
+
+import (
+	"strings"
+	"unicode"
+)
+
+// DefaultPositiveMarkers are the emoticons and emoji used
+// by DistantLabeler to recognize positive text when no
+// other markers are given.
+// The emoticons are those used in
+// http://cs.stanford.edu/people/alecmgo/papers/TwitterDistantSupervision09.pdf.
+var DefaultPositiveMarkers = []string{":)", ":-)", ": )", ":D", "=)",
+	"😀", "😃", "😄", "😁", "😊", "😍", "🙂", "❤️"}
+
+// DefaultNegativeMarkers are the negative counterparts of
+// DefaultPositiveMarkers.
+var DefaultNegativeMarkers = []string{":(", ":-(", ": (", ":'(",
+	"😞", "😢", "😭", "😠", "😡", "🙁", "☹️"}
+
+// A DistantLabeler labels text according to the markers
+// (emoticons, emoji, or hashtags) that it contains, a
+// technique known as distant supervision.
+//
+// Markers beginning with "#" are hashtags, which only
+// match entire words (ignoring case).
+// Other markers match anywhere in the text.
+type DistantLabeler struct {
+	Positive []string
+	Negative []string
+}
+
+// NewDistantLabeler creates a DistantLabeler which uses
+// the default markers.
+func NewDistantLabeler() *DistantLabeler {
+	return &DistantLabeler{
+		Positive: DefaultPositiveMarkers,
+		Negative: DefaultNegativeMarkers,
+	}
+}
+
+// Label determines the sentiment of the text from its
+// markers and returns the text with all of the markers
+// removed, so that a model trained on the result does not
+// simply learn the markers.
+//
+// The last return value is false if the text contains no
+// markers, or contains both positive and negative ones.
+func (d *DistantLabeler) Label(text string) (Sentiment, string, bool) {
+	positive, text := stripMarkers(text, d.Positive)
+	negative, text := stripMarkers(text, d.Negative)
+	text = strings.Join(strings.Fields(text), " ")
+	if positive == negative || text == "" {
+		return Neutral, text, false
+	} else if positive {
+		return Positive, text, true
+	}
+	return Negative, text, true
+}
+
+// stripMarkers removes all of the markers from the text
+// and reports whether any were found.
+func stripMarkers(text string, markers []string) (bool, string) {
+	var found bool
+	for _, marker := range markers {
+		if strings.HasPrefix(marker, "#") {
+			var words []string
+			for _, word := range strings.Fields(text) {
+				trimmed := strings.TrimRightFunc(word, unicode.IsPunct)
+				if strings.EqualFold(trimmed, marker) {
+					found = true
+				} else {
+					words = append(words, word)
+				}
+			}
+			text = strings.Join(words, " ")
+		} else if strings.Contains(text, marker) {
+			found = true
+			text = strings.Replace(text, marker, " ", -1)
+		}
+	}
+	return found, text
+}
@@ -0,0 +1,134 @@
+// Command distant builds a labelled corpus from unlabelled
+// text using distant supervision: documents are labelled
+// according to the emoticons, emoji, or hashtags they
+// contain.
+//
+// The output is in the sentiment140 CSV format, so it can
+// be used directly with the train command.
+package main
+
+import (
+	"bufio"
+	"encoding/csv"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/unixpickle/sentigraph"
+)
+
+const (
+	InputArg  = 0
+	OutputArg = 1
+)
+
+func main() {
+	var positivePath string
+	var negativePath string
+	flag.StringVar(&positivePath, "positive", "",
+		"file of positive markers (one per line) to use instead of the defaults")
+	flag.StringVar(&negativePath, "negative", "",
+		"file of negative markers (one per line) to use instead of the defaults")
+	flag.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage:", os.Args[0], "[flags] input.txt output.csv")
+		fmt.Fprintln(os.Stderr, "\nThe input has one document per line.",
+			"Use - to read from standard input.")
+		fmt.Fprintln(os.Stderr, "\nFlags:")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if flag.NArg() != 2 {
+		flag.Usage()
+		os.Exit(1)
+	}
+
+	labeler := sentigraph.NewDistantLabeler()
+	if positivePath != "" {
+		labeler.Positive = readMarkers(positivePath)
+	}
+	if negativePath != "" {
+		labeler.Negative = readMarkers(negativePath)
+	}
+
+	var input io.Reader = os.Stdin
+	if flag.Arg(InputArg) != "-" {
+		inFile, err := os.Open(flag.Arg(InputArg))
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "Failed to open input:", err)
+			os.Exit(1)
+		}
+		defer inFile.Close()
+		input = inFile
+	}
+
+	outFile, err := os.Create(flag.Arg(OutputArg))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Failed to create output:", err)
+		os.Exit(1)
+	}
+	defer outFile.Close()
+
+	writer := csv.NewWriter(outFile)
+	counts := map[sentigraph.Sentiment]int{}
+	var total int
+
+	scanner := bufio.NewScanner(input)
+	scanner.Buffer(nil, 1<<20)
+	for scanner.Scan() {
+		total++
+		sentiment, text, ok := labeler.Label(scanner.Text())
+		if !ok {
+			continue
+		}
+		counts[sentiment]++
+		label := "0"
+		if sentiment == sentigraph.Positive {
+			label = "4"
+		}
+		record := []string{label, strconv.Itoa(total), "", "NO_QUERY", "", text}
+		if err := writer.Write(record); err != nil {
+			fmt.Fprintln(os.Stderr, "Failed to write output:", err)
+			os.Exit(1)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		fmt.Fprintln(os.Stderr, "Failed to read input:", err)
+		os.Exit(1)
+	}
+
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		fmt.Fprintln(os.Stderr, "Failed to write output:", err)
+		os.Exit(1)
+	}
+
+	log.Printf("Labelled %d/%d documents (%d positive, %d negative)",
+		counts[sentigraph.Positive]+counts[sentigraph.Negative], total,
+		counts[sentigraph.Positive], counts[sentigraph.Negative])
+}
+
+func readMarkers(path string) []string {
+	f, err := os.Open(path)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Failed to open markers:", err)
+		os.Exit(1)
+	}
+	defer f.Close()
+	var res []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if marker := strings.TrimSpace(scanner.Text()); marker != "" {
+			res = append(res, marker)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		fmt.Fprintln(os.Stderr, "Failed to read markers:", err)
+		os.Exit(1)
+	}
+	return res
+}
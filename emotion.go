@@ -0,0 +1,226 @@
+package sentigraph
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+
+	"github.com/unixpickle/serializer"
+)
+
+// An Emotion is one of Plutchik's eight basic emotions.
+// Unlike a Sentiment, a piece of text may express any
+// number of emotions at once.
+type Emotion int
+
+const (
+	Anger Emotion = iota
+	Anticipation
+	Disgust
+	Fear
+	Joy
+	Sadness
+	Surprise
+	Trust
+)
+
+// AllEmotions lists every Emotion in a fixed order.
+var AllEmotions = []Emotion{Anger, Anticipation, Disgust, Fear, Joy,
+	Sadness, Surprise, Trust}
+
+var emotionNames = map[Emotion]string{
+	Anger:        "anger",
+	Anticipation: "anticipation",
+	Disgust:      "disgust",
+	Fear:         "fear",
+	Joy:          "joy",
+	Sadness:      "sadness",
+	Surprise:     "surprise",
+	Trust:        "trust",
+}
+
+// String returns the lowercase name of the emotion, as
+// used in the NRC emotion lexicon.
+func (e Emotion) String() string {
+	if name, ok := emotionNames[e]; ok {
+		return name
+	}
+	return fmt.Sprintf("Emotion(%d)", int(e))
+}
+
+// ParseEmotion finds the Emotion with the given name.
+// The name is not case-sensitive.
+func ParseEmotion(name string) (Emotion, bool) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	for e, n := range emotionNames {
+		if n == name {
+			return e, true
+		}
+	}
+	return 0, false
+}
+
+// EmotionSample is a textual training sample labelled
+// with the set of emotions it expresses.
+type EmotionSample struct {
+	Contents string
+	Emotions map[Emotion]bool
+}
+
+// ReadEmotionSamples reads emotion samples from a CSV
+// stream.
+//
+// Each record has the form:
+//
+//	emotions,ignored...,text_body
+//
+// where emotions is a list of emotion names separated
+// by spaces or semicolons (possibly empty).
+// An optional header row beginning with "emotions" is
+// skipped.
+func ReadEmotionSamples(r io.Reader) ([]*EmotionSample, error) {
+	source := csv.NewReader(r)
+	source.FieldsPerRecord = -1
+	records, err := source.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(records) > 0 && strings.ToLower(records[0][0]) == "emotions" {
+		records = records[1:]
+	}
+	samples := make([]*EmotionSample, len(records))
+	for i, record := range records {
+		if len(record) < 2 {
+			return nil, fmt.Errorf("record %d: expected at least two fields", i)
+		}
+		sample := &EmotionSample{
+			Contents: record[len(record)-1],
+			Emotions: map[Emotion]bool{},
+		}
+		names := strings.FieldsFunc(record[0], func(r rune) bool {
+			return r == ';' || r == ' '
+		})
+		for _, name := range names {
+			emotion, ok := ParseEmotion(name)
+			if !ok {
+				return nil, fmt.Errorf("record %d: invalid emotion %s", i, name)
+			}
+			sample.Emotions[emotion] = true
+		}
+		samples[i] = sample
+	}
+	return samples, nil
+}
+
+// An EmotionModel learns to detect the emotions in text.
+type EmotionModel interface {
+	serializer.Serializer
+
+	// Emotions returns, for each emotion, the probability
+	// that the text expresses it.
+	// It is not valid to call this before the model has
+	// been trained using TrainEmotions.
+	Emotions(text string) map[Emotion]float64
+
+	// TrainEmotions trains the model on the set of
+	// samples.
+	TrainEmotions(samples []*EmotionSample)
+}
+
+// ReadEmotionModel reads an emotion model from a file.
+func ReadEmotionModel(path string) (EmotionModel, error) {
+	modelData, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	modelObj, err := serializer.DeserializeWithType(modelData)
+	if err != nil {
+		return nil, err
+	}
+	model, ok := modelObj.(EmotionModel)
+	if !ok {
+		return nil, fmt.Errorf("invalid emotion model type: %T", modelObj)
+	}
+	return model, nil
+}
+
+// EmotionModels maps emotion model names to functions
+// which construct new instances of those models.
+var EmotionModels = map[string]func() EmotionModel{
+	"emotionBayes": func() EmotionModel {
+		return &EmotionBayes{}
+	},
+	"emotionBayesBigraph": func() EmotionModel {
+		return &EmotionBayes{Bigraph: true}
+	},
+}
+
+// An EmotionLexicon maps words to the emotions they are
+// associated with.
+type EmotionLexicon map[string][]Emotion
+
+// ReadEmotionLexicon reads a word-level emotion lexicon in
+// the format of the NRC Word-Emotion Association Lexicon.
+//
+// Each line has the form:
+//
+//	word<TAB>emotion<TAB>0/1
+//
+// Associations with a value of 0, and associations with
+// categories other than the eight emotions (such as
+// "positive" and "negative"), are ignored.
+func ReadEmotionLexicon(r io.Reader) (EmotionLexicon, error) {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	res := EmotionLexicon{}
+	for i, line := range strings.Split(string(data), "\n") {
+		fields := strings.Split(strings.TrimSpace(line), "\t")
+		if len(fields) == 1 && fields[0] == "" {
+			continue
+		}
+		if len(fields) != 3 {
+			return nil, fmt.Errorf("line %d: expected three fields", i+1)
+		}
+		emotion, ok := ParseEmotion(fields[1])
+		if !ok || fields[2] == "0" {
+			continue
+		} else if fields[2] != "1" {
+			return nil, fmt.Errorf("line %d: invalid association %s", i+1, fields[2])
+		}
+		word := strings.ToLower(fields[0])
+		res[word] = append(res[word], emotion)
+	}
+	if len(res) == 0 {
+		return nil, errors.New("empty emotion lexicon")
+	}
+	return res, nil
+}
+
+// Label finds the emotions associated with any word in
+// the text.
+// This can be used to build training data for an
+// EmotionModel from unlabelled text.
+func (e EmotionLexicon) Label(text string) map[Emotion]bool {
+	res := map[Emotion]bool{}
+	for _, word := range strings.Fields(SeparatePunctuation(Normalize(text))) {
+		for _, emotion := range e[word] {
+			res[emotion] = true
+		}
+	}
+	return res
+}
+
+// LabelSamples uses the lexicon to create an emotion
+// sample for each piece of text.
+func (e EmotionLexicon) LabelSamples(texts []string) []*EmotionSample {
+	res := make([]*EmotionSample, len(texts))
+	for i, text := range texts {
+		res[i] = &EmotionSample{Contents: text, Emotions: e.Label(text)}
+	}
+	return res
+}
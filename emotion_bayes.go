@@ -0,0 +1,130 @@
+package sentigraph
+
+import (
+	"encoding/json"
+	"log"
+	"math"
+
+	"github.com/unixpickle/serializer"
+)
+
+func init() {
+	var e EmotionBayes
+	serializer.RegisterTypedDeserializer(e.SerializerType(), DeserializeEmotionBayes)
+}
+
+// EmotionBayes is a multi-label EmotionModel which uses
+// an independent naive Bayes classifier to decide whether
+// or not each emotion is present.
+type EmotionBayes struct {
+	// Bigraph should be set to true if bigraphs are to
+	// be used in addition to unigraphs.
+	Bigraph bool
+
+	// Total is the number of training samples.
+	Total float64
+
+	// EmotionCounts stores the number of training samples
+	// which expressed each emotion.
+	EmotionCounts map[Emotion]float64
+
+	// Features stores the number of training samples
+	// containing each feature.
+	Features map[string]float64
+
+	// Conditional stores the number of training samples
+	// containing each feature which expressed each
+	// emotion.
+	Conditional map[Emotion]map[string]float64
+}
+
+// DeserializeEmotionBayes deserializes an EmotionBayes
+// model.
+func DeserializeEmotionBayes(d []byte) (*EmotionBayes, error) {
+	var res EmotionBayes
+	if err := json.Unmarshal(d, &res); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+// Emotions computes the probability of each emotion.
+//
+// For efficiency, only the features which are present in
+// the text contribute to the probabilities.
+func (e *EmotionBayes) Emotions(text string) map[Emotion]float64 {
+	features := bayesFeatures(text, e.Bigraph)
+	res := map[Emotion]float64{}
+	for _, emotion := range AllEmotions {
+		count := e.EmotionCounts[emotion]
+		logOdds := math.Log((count + BayesSmoothing) /
+			(e.Total - count + BayesSmoothing))
+		conditional := e.Conditional[emotion]
+		for feature := range features {
+			featureCount, ok := e.Features[feature]
+			if !ok {
+				continue
+			}
+			withEmotion := conditional[feature]
+			probWith := (withEmotion + BayesSmoothing) /
+				(count + 2*BayesSmoothing)
+			probWithout := (featureCount - withEmotion + BayesSmoothing) /
+				(e.Total - count + 2*BayesSmoothing)
+			logOdds += math.Log(probWith / probWithout)
+		}
+		res[emotion] = 1 / (1 + math.Exp(-logOdds))
+	}
+	return res
+}
+
+// TrainEmotions regenerates the classifier using the
+// given list of samples.
+func (e *EmotionBayes) TrainEmotions(s []*EmotionSample) {
+	e.Total = float64(len(s))
+	e.EmotionCounts = map[Emotion]float64{}
+	e.Features = map[string]float64{}
+	e.Conditional = map[Emotion]map[string]float64{}
+	for _, emotion := range AllEmotions {
+		e.Conditional[emotion] = map[string]float64{}
+	}
+
+	log.Println("Counting features...")
+	for _, sample := range s {
+		features := bayesFeatures(sample.Contents, e.Bigraph)
+		for feature := range features {
+			e.Features[feature]++
+		}
+		for emotion, present := range sample.Emotions {
+			if !present {
+				continue
+			}
+			e.EmotionCounts[emotion]++
+			conditional := e.Conditional[emotion]
+			for feature := range features {
+				conditional[feature]++
+			}
+		}
+	}
+
+	log.Println("Pruning features...")
+	for feature, count := range e.Features {
+		if int(count+0.5) < BayesMinFeatureCount {
+			delete(e.Features, feature)
+			for _, m := range e.Conditional {
+				delete(m, feature)
+			}
+		}
+	}
+	log.Println("Kept", len(e.Features), "features.")
+}
+
+// SerializerType gives the unique ID used to serialize
+// EmotionBayes instances with the serializer package.
+func (e *EmotionBayes) SerializerType() string {
+	return "github.com/unixpickle/sentigraph.EmotionBayes"
+}
+
+// Serialize serializes the classifier.
+func (e *EmotionBayes) Serialize() ([]byte, error) {
+	return json.Marshal(e)
+}
@@ -0,0 +1,33 @@
+package sentigraph
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+)
+
+// envInt overrides *value with the integer in the
+// environment variable, if it is set.
+func envInt(name string, value *int) {
+	if str := os.Getenv(name); str != "" {
+		parsed, err := strconv.Atoi(str)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Invalid %s: %s", name, str)
+			return
+		}
+		*value = parsed
+	}
+}
+
+// envFloat is like envInt, but for floating-point
+// values.
+func envFloat(name string, value *float64) {
+	if str := os.Getenv(name); str != "" {
+		parsed, err := strconv.ParseFloat(str, 64)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Invalid %s: %s", name, str)
+			return
+		}
+		*value = parsed
+	}
+}
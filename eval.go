@@ -0,0 +1,347 @@
+package sentigraph
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"runtime"
+	"sync"
+)
+
+// A Confusion maps each actual sentiment to the number of
+// times each sentiment was predicted for it.
+type Confusion map[Sentiment]map[Sentiment]int
+
+// Add records a classification.
+func (c Confusion) Add(actual, predicted Sentiment) {
+	c.add(actual, predicted, 1)
+}
+
+// Classes returns the sentiments which were either
+// actual or predicted, in the order of FineSentiments.
+func (c Confusion) Classes() []Sentiment {
+	present := map[Sentiment]bool{}
+	for actual, row := range c {
+		for predicted, count := range row {
+			if count > 0 {
+				present[actual] = true
+				present[predicted] = true
+			}
+		}
+	}
+	var res []Sentiment
+	for _, sent := range FineSentiments {
+		if present[sent] {
+			res = append(res, sent)
+		}
+	}
+	return res
+}
+
+// Precision returns the fraction of predictions of the
+// sentiment which were correct, or 0 if it was never
+// predicted.
+func (c Confusion) Precision(s Sentiment) float64 {
+	var predicted int
+	for _, row := range c {
+		predicted += row[s]
+	}
+	if predicted == 0 {
+		return 0
+	}
+	return float64(c[s][s]) / float64(predicted)
+}
+
+// Recall returns the fraction of samples of the sentiment
+// which were classified correctly, or 0 if there were no
+// such samples.
+func (c Confusion) Recall(s Sentiment) float64 {
+	var actual int
+	for _, count := range c[s] {
+		actual += count
+	}
+	if actual == 0 {
+		return 0
+	}
+	return float64(c[s][s]) / float64(actual)
+}
+
+// F1 returns the harmonic mean of the precision and
+// recall for the sentiment.
+func (c Confusion) F1(s Sentiment) float64 {
+	precision, recall := c.Precision(s), c.Recall(s)
+	if precision+recall == 0 {
+		return 0
+	}
+	return 2 * precision * recall / (precision + recall)
+}
+
+// MacroF1 returns the mean F1 score of the classes.
+func (c Confusion) MacroF1() float64 {
+	classes := c.Classes()
+	if len(classes) == 0 {
+		return 0
+	}
+	var sum float64
+	for _, class := range classes {
+		sum += c.F1(class)
+	}
+	return sum / float64(len(classes))
+}
+
+func (c Confusion) add(actual, predicted Sentiment, count int) {
+	if c[actual] == nil {
+		c[actual] = map[Sentiment]int{}
+	}
+	c[actual][predicted] += count
+}
+
+// A ReliabilityBin summarizes the predictions whose
+// probabilities fell into one bin of a reliability
+// diagram.
+type ReliabilityBin struct {
+	Min, Max float64
+	Count    int
+
+	// Confidence is the mean probability of the predicted
+	// sentiments, and Accuracy is the fraction of them
+	// which were correct.
+	// For a calibrated model, the two are close.
+	Confidence float64
+	Accuracy   float64
+}
+
+// BatchProbabilities computes the model's probabilities
+// for every sample, in parallel.
+func BatchProbabilities(m ProbabilityModel, samples []*Sample) []map[Sentiment]float64 {
+	res := make([]map[Sentiment]float64, len(samples))
+	numProcs := runtime.GOMAXPROCS(0)
+	var wg sync.WaitGroup
+	for i := 0; i < numProcs; i++ {
+		wg.Add(1)
+		go func(start int) {
+			defer wg.Done()
+			for j := start; j < len(samples); j += numProcs {
+				res[j] = m.Probabilities(samples[j].Contents)
+			}
+		}(i)
+	}
+	wg.Wait()
+	return res
+}
+
+// Reliability bins each prediction (the most likely
+// sentiment) by its probability.
+func Reliability(probs []map[Sentiment]float64, actual []Sentiment,
+	numBins int) []*ReliabilityBin {
+	bins := make([]*ReliabilityBin, numBins)
+	for i := range bins {
+		bins[i] = &ReliabilityBin{
+			Min: float64(i) / float64(numBins),
+			Max: float64(i+1) / float64(numBins),
+		}
+	}
+	for i, p := range probs {
+		predicted, _ := Confidence(p)
+		prob := p[predicted]
+		bin := bins[int(math.Min(prob*float64(numBins), float64(numBins-1)))]
+		bin.Count++
+		bin.Confidence += prob
+		if predicted == actual[i] {
+			bin.Accuracy++
+		}
+	}
+	for _, bin := range bins {
+		if bin.Count > 0 {
+			bin.Confidence /= float64(bin.Count)
+			bin.Accuracy /= float64(bin.Count)
+		}
+	}
+	return bins
+}
+
+// CalibrationError computes the expected calibration
+// error: the mean difference between the confidence and
+// accuracy of each bin, weighted by the bins' sizes.
+func CalibrationError(bins []*ReliabilityBin) float64 {
+	var total int
+	var res float64
+	for _, bin := range bins {
+		total += bin.Count
+		res += float64(bin.Count) * math.Abs(bin.Confidence-bin.Accuracy)
+	}
+	if total == 0 {
+		return 0
+	}
+	return res / float64(total)
+}
+
+// An Evaluation summarizes how well a model classified a
+// labelled corpus.
+// Sentiments are identified by their scores (see
+// Sentiment.Score).
+type Evaluation struct {
+	Samples  int
+	Correct  int
+	Accuracy float64
+
+	// Labels lists the scores of the evaluated
+	// sentiments, which are AllSentiments along with any
+	// other sentiments which were actual or predicted.
+	Labels []int
+
+	// Confusion[i][j] is the number of samples of
+	// sentiment Labels[i] which were classified as
+	// sentiment Labels[j].
+	Confusion [][]int
+
+	Classes []*ClassMetrics
+
+	// The macro averages are the means of the metrics of
+	// the classes which were actual or predicted at least
+	// once.
+	// The micro averages pool every classification, so
+	// they all equal the accuracy.
+	MacroPrecision float64
+	MacroRecall    float64
+	MacroF1        float64
+	MicroPrecision float64
+	MicroRecall    float64
+	MicroF1        float64
+
+	// Kappa is Cohen's kappa, which measures agreement
+	// with the actual sentiments beyond what would be
+	// expected by chance.
+	Kappa float64
+
+	// NeverPredicted lists the labels which the model
+	// never predicted.
+	NeverPredicted []int
+}
+
+// ClassMetrics stores the metrics for one sentiment.
+type ClassMetrics struct {
+	Label     int
+	Support   int
+	Predicted int
+	Precision float64
+	Recall    float64
+	F1        float64
+}
+
+// Evaluate computes an Evaluation from a Confusion.
+func Evaluate(c Confusion) *Evaluation {
+	present := map[Sentiment]bool{}
+	for _, sent := range AllSentiments {
+		present[sent] = true
+	}
+	for _, sent := range c.Classes() {
+		present[sent] = true
+	}
+	var classes []Sentiment
+	for _, sent := range FineSentiments {
+		if present[sent] {
+			classes = append(classes, sent)
+		}
+	}
+
+	res := &Evaluation{Confusion: make([][]int, len(classes))}
+	actualCounts := make([]int, len(classes))
+	predictedCounts := make([]int, len(classes))
+	for i, actual := range classes {
+		res.Labels = append(res.Labels, actual.Score())
+		res.Confusion[i] = make([]int, len(classes))
+		for j, predicted := range classes {
+			count := c[actual][predicted]
+			res.Confusion[i][j] = count
+			res.Samples += count
+			actualCounts[i] += count
+			predictedCounts[j] += count
+		}
+		res.Correct += c[actual][actual]
+	}
+	if res.Samples > 0 {
+		res.Accuracy = float64(res.Correct) / float64(res.Samples)
+	}
+	res.MicroPrecision = res.Accuracy
+	res.MicroRecall = res.Accuracy
+	res.MicroF1 = res.Accuracy
+
+	var numAveraged int
+	for i, class := range classes {
+		metrics := &ClassMetrics{
+			Label:     class.Score(),
+			Support:   actualCounts[i],
+			Predicted: predictedCounts[i],
+			Precision: c.Precision(class),
+			Recall:    c.Recall(class),
+			F1:        c.F1(class),
+		}
+		res.Classes = append(res.Classes, metrics)
+		if metrics.Predicted == 0 {
+			res.NeverPredicted = append(res.NeverPredicted, metrics.Label)
+		}
+		if metrics.Support > 0 || metrics.Predicted > 0 {
+			res.MacroPrecision += metrics.Precision
+			res.MacroRecall += metrics.Recall
+			res.MacroF1 += metrics.F1
+			numAveraged++
+		}
+	}
+	if numAveraged > 0 {
+		res.MacroPrecision /= float64(numAveraged)
+		res.MacroRecall /= float64(numAveraged)
+		res.MacroF1 /= float64(numAveraged)
+	}
+
+	if res.Samples > 0 {
+		var chance float64
+		total := float64(res.Samples)
+		for i := range classes {
+			chance += float64(actualCounts[i]) / total * float64(predictedCounts[i]) / total
+		}
+		if chance < 1 {
+			res.Kappa = (res.Accuracy - chance) / (1 - chance)
+		}
+	}
+
+	return res
+}
+
+// String returns a human-readable report.
+func (e *Evaluation) String() string {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "accuracy: %d/%d (%.2f%%)\n", e.Correct, e.Samples,
+		e.Accuracy*100)
+	fmt.Fprintf(&buf, "Cohen's kappa: %.4f\n", e.Kappa)
+
+	fmt.Fprintln(&buf, "\nconfusion matrix (rows are actual, columns are predicted):")
+	fmt.Fprintf(&buf, "%-10s", "actual")
+	for _, label := range e.Labels {
+		fmt.Fprintf(&buf, " %10d", label)
+	}
+	fmt.Fprintln(&buf)
+	for i, label := range e.Labels {
+		fmt.Fprintf(&buf, "%-10d", label)
+		for _, count := range e.Confusion[i] {
+			fmt.Fprintf(&buf, " %10d", count)
+		}
+		fmt.Fprintln(&buf)
+	}
+
+	fmt.Fprintf(&buf, "\n%-10s %10s %10s %10s %10s\n", "class", "precision",
+		"recall", "f1", "support")
+	for _, class := range e.Classes {
+		fmt.Fprintf(&buf, "%-10d %10.4f %10.4f %10.4f %10d\n", class.Label,
+			class.Precision, class.Recall, class.F1, class.Support)
+	}
+	fmt.Fprintf(&buf, "%-10s %10.4f %10.4f %10.4f %10d\n", "macro",
+		e.MacroPrecision, e.MacroRecall, e.MacroF1, e.Samples)
+	fmt.Fprintf(&buf, "%-10s %10.4f %10.4f %10.4f %10d\n", "micro",
+		e.MicroPrecision, e.MicroRecall, e.MicroF1, e.Samples)
+
+	if len(e.NeverPredicted) > 0 {
+		fmt.Fprintf(&buf, "\nnever predicted: %v\n", e.NeverPredicted)
+	}
+	return buf.String()
+}
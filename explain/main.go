@@ -0,0 +1,110 @@
+// Command explain shows the training samples behind a
+// kNN model's predictions.
+//
+// It can either explain the classification of each line
+// of text read from standard input, or check a labelled
+// corpus for samples whose labels disagree with their
+// neighbors (which often indicates label noise).
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/unixpickle/sentigraph"
+)
+
+const ModelArg = 0
+
+func main() {
+	var corpusPath string
+	flag.StringVar(&corpusPath, "check", "",
+		"corpus to check for labels which disagree with their neighbors")
+	flag.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage:", os.Args[0], "[flags] knn_model_file")
+		fmt.Fprintln(os.Stderr, "\nWithout -check, text is read from standard input,",
+			"one document per line.")
+		fmt.Fprintln(os.Stderr, "\nFlags:")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(1)
+	}
+
+	model, err := sentigraph.ReadModel(flag.Arg(ModelArg))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Failed to read model:", err)
+		os.Exit(1)
+	}
+	knn, ok := model.(*sentigraph.KNN)
+	if !ok {
+		fmt.Fprintf(os.Stderr, "Not a kNN model: %T\n", model)
+		os.Exit(1)
+	}
+
+	if corpusPath != "" {
+		checkCorpus(knn, corpusPath)
+	} else {
+		explainInput(knn)
+	}
+}
+
+func explainInput(knn *sentigraph.KNN) {
+	scanner := bufio.NewScanner(os.Stdin)
+	for scanner.Scan() {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+		fmt.Printf("%s\n  classified %d because it resembles:\n", text,
+			knn.Classify(text).Score())
+		for _, n := range knn.Neighbors(text) {
+			fmt.Printf("    %.3f  %2d  %s\n", n.Similarity, n.Sentiment.Score(), n.Text)
+		}
+		fmt.Println()
+	}
+	if err := scanner.Err(); err != nil {
+		fmt.Fprintln(os.Stderr, "Failed to read input:", err)
+		os.Exit(1)
+	}
+}
+
+func checkCorpus(knn *sentigraph.KNN, path string) {
+	f, err := os.Open(path)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Failed to open corpus:", err)
+		os.Exit(1)
+	}
+	defer f.Close()
+	samples, err := sentigraph.ReadSamples(f)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Failed to parse corpus:", err)
+		os.Exit(1)
+	}
+
+	var suspicious int
+	for _, sample := range samples {
+		votes := map[sentigraph.Sentiment]float64{}
+		for _, n := range knn.Neighbors(sample.Contents) {
+			// Skip the sample itself, if the model was
+			// trained on this corpus.
+			if n.Text != sample.Contents {
+				votes[n.Sentiment] += n.Similarity
+			}
+		}
+		majority, _ := sentigraph.Confidence(votes)
+		if len(votes) > 0 && majority != sample.Sentiment {
+			suspicious++
+			fmt.Printf("labelled %2d, neighbors say %2d: %s\n",
+				sample.Sentiment.Score(), majority.Score(), sample.Contents)
+		}
+	}
+	fmt.Printf("%d/%d samples disagree with their neighbors\n", suspicious,
+		len(samples))
+}
@@ -1,14 +1,15 @@
 package sentigraph
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
-	"fmt"
 	"log"
-	"os"
+	"math/rand"
 	"runtime"
-	"strconv"
+	"sort"
 	"strings"
+	"sync"
 
 	"github.com/unixpickle/serializer"
 	"github.com/unixpickle/weakai/idtrees"
@@ -19,11 +20,21 @@ import (
 // generating each tree.
 const ForestSampleCountEnvVar = "FOREST_SAMPLE_COUNT"
 
+// These environment variables override the corresponding
+// Forest hyperparameters during training.
+const (
+	ForestMaxDepthEnvVar      = "FOREST_MAX_DEPTH"
+	ForestMinLeafEnvVar       = "FOREST_MIN_LEAF"
+	ForestSplitFeaturesEnvVar = "FOREST_SPLIT_FEATURES"
+)
+
 func init() {
 	var f Forest
 	var t treeSerializer
+	var p forestParams
 	serializer.RegisterTypedDeserializer(f.SerializerType(), DeserializeForest)
 	serializer.RegisterTypedDeserializer(t.SerializerType(), deserializeTreeSerializer)
+	serializer.RegisterTypedDeserializer(p.SerializerType(), deserializeForestParams)
 }
 
 // ForestSize is the size of the random forests build by
@@ -40,6 +51,35 @@ type Forest struct {
 	// Forest is the learned model.
 	// It is nil if no model has been trained.
 	Forest idtrees.Forest
+
+	// Seed seeds the random number generator used for
+	// training.
+	// If it is 0 when training begins, a seed is chosen
+	// at random and stored here, so that the training
+	// can be reproduced later.
+	Seed int64
+
+	// OOB is the out-of-bag estimate of the forest's
+	// performance, computed during training.
+	// It is nil for forests trained before OOB estimates
+	// were introduced, and for forests whose training
+	// was cancelled.
+	OOB *OOBEstimate
+
+	// MaxDepth limits the depth of each tree.
+	// If it is 0, the depth is unlimited.
+	MaxDepth int
+
+	// MinLeaf is the minimum number of samples in each
+	// leaf of a tree.
+	MinLeaf int
+
+	// SplitFeatures is the number of randomly chosen
+	// features to consider at each split, drawn from the
+	// features present in the samples being split.
+	// If it is 0, every feature is considered.
+	// It may be ForestSqrtFeatures.
+	SplitFeatures int
 }
 
 // DeserializeForest deserializes a forest.
@@ -57,7 +97,32 @@ func DeserializeForest(d []byte) (*Forest, error) {
 	}
 	var res Forest
 	res.Bigraph = intVal == 1
-	for _, t := range slice[1:] {
+	slice = slice[1:]
+
+	// Forests saved before seeds were introduced have no
+	// seed element.
+	if len(slice) > 0 {
+		if seed, ok := slice[0].(serializer.Int); ok {
+			res.Seed = int64(seed)
+			slice = slice[1:]
+		}
+	}
+	if len(slice) > 0 {
+		if params, ok := slice[0].(*forestParams); ok {
+			res.MaxDepth = params.MaxDepth
+			res.MinLeaf = params.MinLeaf
+			res.SplitFeatures = params.SplitFeatures
+			slice = slice[1:]
+		}
+	}
+	if len(slice) > 0 {
+		if oob, ok := slice[0].(*OOBEstimate); ok {
+			res.OOB = oob
+			slice = slice[1:]
+		}
+	}
+
+	for _, t := range slice {
 		tree, ok := t.(*treeSerializer)
 		if !ok {
 			return nil, errors.New("invalid Forest slice")
@@ -84,8 +149,36 @@ func (f *Forest) Classify(text string) Sentiment {
 	return maxClass
 }
 
+// Probabilities returns the fraction of the forest's
+// votes for each sentiment.
+func (f *Forest) Probabilities(text string) map[Sentiment]float64 {
+	classes := f.Forest.Classify(newForestSampleText(f.Bigraph, text))
+	res := map[Sentiment]float64{}
+	var total float64
+	for class, prob := range classes {
+		res[class.(Sentiment)] = prob
+		total += prob
+	}
+	if total > 0 {
+		for sentiment := range res {
+			res[sentiment] /= total
+		}
+	}
+	return res
+}
+
 // Train generates a forest for the training data.
+// Each tree is trained on a bootstrap subsample, in which
+// samples are drawn in proportion to their weights.
 func (f *Forest) Train(data []*Sample) {
+	f.TrainContext(context.Background(), data, nil)
+}
+
+// TrainContext is like Train, but it reports the number
+// of trees built and can be cancelled.
+// If it is cancelled, the forest contains the trees which
+// were finished before cancellation.
+func (f *Forest) TrainContext(ctx context.Context, data []*Sample, p ProgressFunc) error {
 	log.Println("Creating samples...")
 	samples := make([]idtrees.Sample, len(data))
 	features := map[string]bool{}
@@ -99,25 +192,97 @@ func (f *Forest) Train(data []*Sample) {
 
 	log.Println("Created", len(samples), "samples with", len(features), "features")
 
-	attrs := make([]idtrees.Attr, 0, len(features))
+	// Sort the features so that the trees do not depend
+	// on the order of map iteration.
+	featureList := make([]string, 0, len(features))
 	for feature := range features {
-		attrs = append(attrs, feature)
+		featureList = append(featureList, feature)
 	}
+	sort.Strings(featureList)
 
 	subsampleCount := len(samples) / 2
 
-	if countStr := os.Getenv(ForestSampleCountEnvVar); countStr != "" {
-		var err error
-		subsampleCount, err = strconv.Atoi(countStr)
-		if err != nil {
-			fmt.Fprintf(os.Stderr, "Invalid %s: %s", ForestSampleCountEnvVar, countStr)
+	envInt(ForestSampleCountEnvVar, &subsampleCount)
+	envInt(ForestMaxDepthEnvVar, &f.MaxDepth)
+	envInt(ForestMinLeafEnvVar, &f.MinLeaf)
+	envInt(ForestSplitFeaturesEnvVar, &f.SplitFeatures)
+
+	// Each tree is built by one goroutine with its own
+	// seed, so the forest does not depend on scheduling.
+	buildParallel := runtime.GOMAXPROCS(0)
+
+	if f.Seed == 0 {
+		f.Seed = NewSeed()
+	}
+	r := rand.New(rand.NewSource(f.Seed))
+	bootstrap := newWeightedBootstrap(data, r)
+	oob := newOOBTracker(samples)
+	f.Forest = make(idtrees.Forest, 0, ForestSize)
+	f.OOB = nil
+	for len(f.Forest) < ForestSize {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		batchSize := ForestSize - len(f.Forest)
+		if batchSize > buildParallel {
+			batchSize = buildParallel
+		}
+		subsamples := make([][]idtrees.Sample, batchSize)
+		inBags := make([]map[int]bool, batchSize)
+		seeds := make([]int64, batchSize)
+		for i := range subsamples {
+			subsamples[i] = make([]idtrees.Sample, subsampleCount)
+			inBags[i] = map[int]bool{}
+			for j := range subsamples[i] {
+				idx := bootstrap.Index()
+				subsamples[i][j] = samples[idx]
+				inBags[i][idx] = true
+			}
+			seeds[i] = r.Int63()
+		}
+
+		trees := make([]*idtrees.Tree, batchSize)
+		var wg sync.WaitGroup
+		for i := range trees {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				trees[i] = f.buildTree(subsamples[i], featureList, seeds[i])
+			}(i)
+		}
+		wg.Wait()
+
+		for i, tree := range trees {
+			f.Forest = append(f.Forest, tree)
+			oob.AddTree(tree, inBags[i])
 		}
+		if p != nil {
+			p(TrainProgress{Stage: "trees", Done: len(f.Forest), Total: ForestSize})
+		}
+	}
+
+	f.OOB = oob.Estimate()
+	log.Print(f.OOB)
+	return nil
+}
+
+// buildTree builds a single tree of the forest.
+func (f *Forest) buildTree(samples []idtrees.Sample, features []string,
+	seed int64) *idtrees.Tree {
+	builder := &treeBuilder{
+		attrs:         features,
+		maxDepth:      f.MaxDepth,
+		minLeaf:       f.MinLeaf,
+		splitFeatures: f.SplitFeatures,
+		rand:          rand.New(rand.NewSource(seed)),
 	}
+	return builder.Build(samples)
+}
 
-	f.Forest = idtrees.BuildForest(ForestSize, samples, attrs, subsampleCount, 0,
-		func(s []idtrees.Sample, attrs []idtrees.Attr) *idtrees.Tree {
-			return idtrees.ID3(s, attrs, runtime.GOMAXPROCS(0))
-		})
+// SetSeed sets f.Seed.
+func (f *Forest) SetSeed(seed int64) {
+	f.Seed = seed
 }
 
 // SerializerType gives the unique ID used to serialize
@@ -132,14 +297,48 @@ func (f *Forest) Serialize() ([]byte, error) {
 	if f.Bigraph {
 		bigraph = 1
 	}
-	serializers := make([]serializer.Serializer, len(f.Forest)+1)
-	serializers[0] = bigraph
-	for i, t := range f.Forest {
-		serializers[i+1] = newTreeSerializer(t)
+	params := &forestParams{
+		MaxDepth:      f.MaxDepth,
+		MinLeaf:       f.MinLeaf,
+		SplitFeatures: f.SplitFeatures,
+	}
+	serializers := []serializer.Serializer{bigraph, serializer.Int(f.Seed), params}
+	if f.OOB != nil {
+		serializers = append(serializers, f.OOB)
+	}
+	for _, t := range f.Forest {
+		serializers = append(serializers, newTreeSerializer(t))
 	}
 	return serializer.SerializeSlice(serializers)
 }
 
+// weightedBootstrap draws random sample indices with
+// probabilities proportional to the samples' weights.
+type weightedBootstrap struct {
+	cumulative []float64
+	rand       *rand.Rand
+}
+
+func newWeightedBootstrap(s []*Sample, r *rand.Rand) *weightedBootstrap {
+	res := &weightedBootstrap{cumulative: make([]float64, len(s)), rand: r}
+	var sum float64
+	for i, sample := range s {
+		sum += sample.TrainingWeight()
+		res.cumulative[i] = sum
+	}
+	return res
+}
+
+// Index returns a random sample index.
+func (w *weightedBootstrap) Index() int {
+	total := w.cumulative[len(w.cumulative)-1]
+	idx := sort.SearchFloat64s(w.cumulative, w.rand.Float64()*total)
+	if idx == len(w.cumulative) {
+		idx--
+	}
+	return idx
+}
+
 type forestSample struct {
 	features map[string]bool
 	class    Sentiment
@@ -173,6 +372,29 @@ func (f *forestSample) Class() idtrees.Class {
 	return f.class
 }
 
+// forestParams stores the hyperparameters of a Forest.
+type forestParams struct {
+	MaxDepth      int
+	MinLeaf       int
+	SplitFeatures int
+}
+
+func deserializeForestParams(d []byte) (*forestParams, error) {
+	var p forestParams
+	if err := json.Unmarshal(d, &p); err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+func (p *forestParams) SerializerType() string {
+	return "github.com/unixpickle/sentigraph.forestParams"
+}
+
+func (p *forestParams) Serialize() ([]byte, error) {
+	return json.Marshal(p)
+}
+
 type treeSerializer struct {
 	Classification map[int]float64 `json:"c"`
 	Attr           string          `json:"w"`
@@ -0,0 +1,170 @@
+package sentigraph
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+
+	"github.com/unixpickle/weakai/idtrees"
+)
+
+// ForestSqrtFeatures may be used as Forest.SplitFeatures
+// to consider the square root of the number of features
+// at each split, as is typical for random forests.
+const ForestSqrtFeatures = -1
+
+// treeBuilder builds decision trees with an optional
+// depth limit, minimum leaf size, and random feature
+// subsampling at each split.
+type treeBuilder struct {
+	attrs         []string
+	maxDepth      int
+	minLeaf       int
+	splitFeatures int
+	rand          *rand.Rand
+}
+
+// Build builds a tree for the samples, which must all be
+// *forestSamples.
+func (t *treeBuilder) Build(samples []idtrees.Sample) *idtrees.Tree {
+	fs := make([]*forestSample, len(samples))
+	for i, s := range samples {
+		fs[i] = s.(*forestSample)
+	}
+	return t.build(fs, 0)
+}
+
+func (t *treeBuilder) build(samples []*forestSample, depth int) *idtrees.Tree {
+	classCounts := forestClassCounts(samples)
+	if (t.maxDepth > 0 && depth >= t.maxDepth) || len(samples) < 2*t.minLeaf ||
+		forestEntropy(classCounts) == 0 {
+		return forestLeaf(classCounts, len(samples))
+	}
+
+	attr, ok := t.bestSplit(samples, classCounts)
+	if !ok {
+		return forestLeaf(classCounts, len(samples))
+	}
+
+	var trueSamples, falseSamples []*forestSample
+	for _, s := range samples {
+		if s.features[attr] {
+			trueSamples = append(trueSamples, s)
+		} else {
+			falseSamples = append(falseSamples, s)
+		}
+	}
+	return &idtrees.Tree{
+		Attr: attr,
+		ValSplit: map[idtrees.Val]*idtrees.Tree{
+			true:  t.build(trueSamples, depth+1),
+			false: t.build(falseSamples, depth+1),
+		},
+	}
+}
+
+// bestSplit finds the candidate feature with the highest
+// information gain, subject to the minimum leaf size.
+// It returns false if no feature improves on the
+// entropy of the unsplit samples.
+func (t *treeBuilder) bestSplit(samples []*forestSample,
+	classCounts []float64) (string, bool) {
+	trueCounts := map[string][]float64{}
+	for _, s := range samples {
+		for feature := range s.features {
+			counts := trueCounts[feature]
+			if counts == nil {
+				counts = make([]float64, len(FineSentiments))
+				trueCounts[feature] = counts
+			}
+			counts[s.class]++
+		}
+	}
+
+	features := make([]string, 0, len(trueCounts))
+	for feature := range trueCounts {
+		features = append(features, feature)
+	}
+	sort.Strings(features)
+	features = t.candidates(features)
+
+	total := float64(len(samples))
+	bestEntropy := forestEntropy(classCounts)
+	var bestAttr string
+	var found bool
+	falseCounts := make([]float64, len(classCounts))
+	for _, feature := range features {
+		counts := trueCounts[feature]
+		var numTrue float64
+		for i, c := range counts {
+			numTrue += c
+			falseCounts[i] = classCounts[i] - c
+		}
+		numFalse := total - numTrue
+		if numTrue < float64(t.minLeaf) || numFalse < float64(t.minLeaf) ||
+			numFalse == 0 {
+			continue
+		}
+		entropy := (numTrue*forestEntropy(counts) +
+			numFalse*forestEntropy(falseCounts)) / total
+		if entropy < bestEntropy {
+			bestEntropy = entropy
+			bestAttr = feature
+			found = true
+		}
+	}
+	return bestAttr, found
+}
+
+// candidates picks the random subset of the features
+// present at a node to consider for a split.
+// Features which are absent from every sample at the
+// node cannot split it, so they are never drawn.
+func (t *treeBuilder) candidates(present []string) []string {
+	count := t.splitFeatures
+	if count == ForestSqrtFeatures {
+		count = int(math.Ceil(math.Sqrt(float64(len(t.attrs)))))
+	}
+	if count <= 0 || count >= len(present) {
+		return present
+	}
+	res := append([]string{}, present...)
+	for i := 0; i < count; i++ {
+		j := i + t.rand.Intn(len(res)-i)
+		res[i], res[j] = res[j], res[i]
+	}
+	return res[:count]
+}
+
+func forestClassCounts(samples []*forestSample) []float64 {
+	res := make([]float64, len(FineSentiments))
+	for _, s := range samples {
+		res[s.class]++
+	}
+	return res
+}
+
+func forestLeaf(classCounts []float64, total int) *idtrees.Tree {
+	classification := map[idtrees.Class]float64{}
+	for class, count := range classCounts {
+		if count > 0 {
+			classification[Sentiment(class)] = count / float64(total)
+		}
+	}
+	return &idtrees.Tree{Classification: classification}
+}
+
+func forestEntropy(classCounts []float64) float64 {
+	var total float64
+	for _, c := range classCounts {
+		total += c
+	}
+	var res float64
+	for _, c := range classCounts {
+		if c > 0 {
+			p := c / total
+			res -= p * math.Log(p)
+		}
+	}
+	return res
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+	"sort"
+
+	"github.com/llgcode/draw2d/draw2dimg"
+)
+
+const (
+	CharacterPointCount = 30
+)
+
+// characterColors are assigned to characters in order of
+// decreasing number of mentions.
+var characterColors = []color.RGBA{
+	{R: 0x1f, G: 0x77, B: 0xb4, A: 0xff},
+	{R: 0xff, G: 0x7f, B: 0x0e, A: 0xff},
+	{R: 0x2c, G: 0xa0, B: 0x2c, A: 0xff},
+	{R: 0xd6, G: 0x27, B: 0x28, A: 0xff},
+	{R: 0x94, G: 0x67, B: 0xbd, A: 0xff},
+	{R: 0x8c, G: 0x56, B: 0x4b, A: 0xff},
+	{R: 0xe3, G: 0x77, B: 0xc2, A: 0xff},
+	{R: 0x7f, G: 0x7f, B: 0x7f, A: 0xff},
+}
+
+// characterGraph draws one sentiment line per character,
+// in the style of lineGraph.
+// Since the image has no legend, the color of each
+// character's line is printed to standard output.
+func characterGraph(points []*DataPoint, maxScore int) image.Image {
+	byCharacter := map[string][]*DataPoint{}
+	for _, p := range points {
+		byCharacter[p.Character] = append(byCharacter[p.Character], p)
+	}
+	var names []string
+	for name := range byCharacter {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		if len(byCharacter[names[i]]) != len(byCharacter[names[j]]) {
+			return len(byCharacter[names[i]]) > len(byCharacter[names[j]])
+		}
+		return names[i] < names[j]
+	})
+
+	res := image.NewRGBA(image.Rect(0, 0, LineImageWidth, LineImageHeight))
+	ctx := draw2dimg.NewGraphicContext(res)
+	ctx.SetLineWidth(LineWidth)
+
+	for i, name := range names {
+		c := characterColors[i%len(characterColors)]
+		fmt.Printf("%s: #%02x%02x%02x (%d mentions)\n", name, c.R, c.G, c.B,
+			len(byCharacter[name]))
+		ctx.SetStrokeColor(c)
+		ys := fillGaps(lineDataPoints(byCharacter[name], CharacterPointCount, maxScore))
+		ctx.BeginPath()
+		for j, y := range ys {
+			x := float64(j) * LineImageWidth / (CharacterPointCount - 1)
+			if j == 0 {
+				ctx.MoveTo(x, LineImageHeight/2-y*(LineImageHeight/2))
+			} else {
+				ctx.LineTo(x, LineImageHeight/2-y*(LineImageHeight/2))
+			}
+		}
+		ctx.Stroke()
+	}
+
+	return res
+}
+
+// fillGaps replaces the NaN values produced for empty
+// buckets (where a character is not mentioned) with the
+// nearest preceding value, or the first known value if
+// there is no preceding one.
+func fillGaps(ys []float64) []float64 {
+	res := make([]float64, len(ys))
+	last := math.NaN()
+	for _, y := range ys {
+		if !math.IsNaN(y) {
+			last = y
+			break
+		}
+	}
+	for i, y := range ys {
+		if !math.IsNaN(y) {
+			last = y
+		}
+		res[i] = last
+	}
+	return res
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"image"
+	"image/color"
+
+	"github.com/llgcode/draw2d/draw2dimg"
+	"github.com/unixpickle/sentigraph"
+)
+
+const (
+	EmotionPointCount = 70
+	EmotionImageWidth = 400
+	EmotionBandHeight = 25
+)
+
+// EmotionImageHeight fits one band for each emotion.
+var EmotionImageHeight = EmotionBandHeight * len(sentigraph.AllEmotions)
+
+// emotionColors roughly follows the colors of Plutchik's
+// wheel of emotions.
+var emotionColors = map[sentigraph.Emotion]color.RGBA{
+	sentigraph.Anger:        {R: 0xe5, G: 0x1c, B: 0x23, A: 0xff},
+	sentigraph.Anticipation: {R: 0xf5, G: 0x8b, B: 0x1f, A: 0xff},
+	sentigraph.Disgust:      {R: 0x8e, G: 0x44, B: 0xad, A: 0xff},
+	sentigraph.Fear:         {R: 0x1b, G: 0x7a, B: 0x3d, A: 0xff},
+	sentigraph.Joy:          {R: 0xf7, G: 0xd3, B: 0x1e, A: 0xff},
+	sentigraph.Sadness:      {R: 0x1e, G: 0x56, B: 0xc8, A: 0xff},
+	sentigraph.Surprise:     {R: 0x2b, G: 0xb6, B: 0xd6, A: 0xff},
+	sentigraph.Trust:        {R: 0x8b, G: 0xc3, B: 0x4a, A: 0xff},
+}
+
+// emotionGraph draws one horizontal band per emotion,
+// stacked vertically, where the opacity of each band
+// indicates how strongly the emotion is expressed at
+// that point in the text.
+func emotionGraph(d []*DataPoint) image.Image {
+	res := image.NewRGBA(image.Rect(0, 0, EmotionImageWidth, EmotionImageHeight))
+	ctx := draw2dimg.NewGraphicContext(res)
+
+	ctx.SetFillColor(color.White)
+	ctx.BeginPath()
+	ctx.MoveTo(0, 0)
+	ctx.LineTo(EmotionImageWidth, 0)
+	ctx.LineTo(EmotionImageWidth, float64(EmotionImageHeight))
+	ctx.LineTo(0, float64(EmotionImageHeight))
+	ctx.Close()
+	ctx.Fill()
+
+	for band, emotion := range sentigraph.AllEmotions {
+		points := emotionDataPoints(d, band, EmotionPointCount)
+		var maxVal float64
+		for _, p := range points {
+			if p > maxVal {
+				maxVal = p
+			}
+		}
+		if maxVal == 0 {
+			continue
+		}
+		c := emotionColors[emotion]
+		y := float64(band * EmotionBandHeight)
+		for i, p := range points {
+			intensity := p / maxVal
+			ctx.SetFillColor(color.RGBA{
+				R: uint8(0xff - (0xff-float64(c.R))*intensity + 0.5),
+				G: uint8(0xff - (0xff-float64(c.G))*intensity + 0.5),
+				B: uint8(0xff - (0xff-float64(c.B))*intensity + 0.5),
+				A: 0xff,
+			})
+			x := float64(i) * EmotionImageWidth / EmotionPointCount
+			nextX := float64(i+1) * EmotionImageWidth / EmotionPointCount
+			ctx.BeginPath()
+			ctx.MoveTo(x, y)
+			ctx.LineTo(nextX, y)
+			ctx.LineTo(nextX, y+EmotionBandHeight)
+			ctx.LineTo(x, y+EmotionBandHeight)
+			ctx.Close()
+			ctx.Fill()
+		}
+	}
+
+	return res
+}
+
+// emotionDataPoints computes the mean probability of the
+// emotion at the given index in each of count buckets.
+func emotionDataPoints(points []*DataPoint, index, count int) []float64 {
+	means := make([]float64, count)
+	counts := make([]float64, count)
+	for _, point := range points {
+		xVal := int(point.Position * float64(count))
+		if xVal == count {
+			xVal = count - 1
+		}
+		means[xVal] += point.Emotions[index]
+		counts[xVal]++
+	}
+	for i, c := range counts {
+		if c > 0 {
+			means[i] /= c
+		}
+	}
+	return means
+}
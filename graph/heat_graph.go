@@ -14,8 +14,8 @@ const (
 	HeatImageHeight = 100
 )
 
-func heatGraph(d []*DataPoint) image.Image {
-	points := lineDataPoints(d, HeatPointCount)
+func heatGraph(d []*DataPoint, maxScore int) image.Image {
+	points := lineDataPoints(d, HeatPointCount, maxScore)
 
 	var mean float64
 	var variance float64
@@ -5,7 +5,6 @@ import (
 	"image/color"
 
 	"github.com/llgcode/draw2d/draw2dimg"
-	"github.com/unixpickle/sentigraph"
 )
 
 const (
@@ -15,7 +14,7 @@ const (
 	LineWidth       = 2
 )
 
-func lineGraph(points []*DataPoint) image.Image {
+func lineGraph(points []*DataPoint, maxScore int) image.Image {
 	res := image.NewRGBA(image.Rect(0, 0, LineImageWidth, LineImageHeight))
 	ctx := draw2dimg.NewGraphicContext(res)
 
@@ -23,7 +22,7 @@ func lineGraph(points []*DataPoint) image.Image {
 	ctx.SetLineWidth(LineWidth)
 
 	ctx.BeginPath()
-	for i, y := range lineDataPoints(points, LinePointCount) {
+	for i, y := range lineDataPoints(points, LinePointCount, maxScore) {
 		x := float64(i) * LineImageWidth / (LinePointCount - 1)
 		if x == 0 {
 			ctx.MoveTo(0, LineImageHeight/2-y*(LineImageHeight/2))
@@ -36,7 +35,12 @@ func lineGraph(points []*DataPoint) image.Image {
 	return res
 }
 
-func lineDataPoints(points []*DataPoint, count int) []float64 {
+// lineDataPoints computes the mean sentiment score in
+// each of count buckets, using the smoothed moods if the
+// input has them.
+// The scores are divided by maxScore, the largest score
+// the model can produce, scaling them to [-1, 1].
+func lineDataPoints(points []*DataPoint, count, maxScore int) []float64 {
 	yMean := make([]float64, count)
 	yCount := make([]float64, count)
 	for _, point := range points {
@@ -44,12 +48,7 @@ func lineDataPoints(points []*DataPoint, count int) []float64 {
 		if xVal == count {
 			xVal = count - 1
 		}
-		switch point.Sentiment {
-		case sentigraph.Positive:
-			yMean[xVal]++
-		case sentigraph.Negative:
-			yMean[xVal]--
-		}
+		yMean[xVal] += float64(point.Mood().Score()) / float64(maxScore)
 		yCount[xVal]++
 	}
 	for i, c := range yCount {
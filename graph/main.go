@@ -5,6 +5,7 @@ package main
 
 import (
 	"encoding/csv"
+	"flag"
 	"fmt"
 	"image"
 	"image/png"
@@ -15,34 +16,80 @@ import (
 )
 
 const (
-	InputArg  = 1
-	OutputArg = 2
-	StyleArg  = 3
+	InputArg  = 0
+	OutputArg = 1
+	StyleArg  = 2
 )
 
 type DataPoint struct {
 	Sentiment sentigraph.Sentiment
 	Position  float64
+
+	// Emotions stores the probability of each emotion,
+	// in the order of sentigraph.AllEmotions.
+	// It is nil if the input has no emotion columns.
+	Emotions []float64
+
+	// Character is the name of the character that this
+	// point pertains to, or "" if the input does not
+	// track characters.
+	Character string
+
+	// Smoothed is the mood chosen by plotcsv's hidden
+	// Markov model, or nil if the input is not smoothed.
+	Smoothed *sentigraph.Sentiment
+}
+
+// Mood returns the smoothed sentiment if there is one, or
+// the raw sentiment otherwise.
+func (d *DataPoint) Mood() sentigraph.Sentiment {
+	if d.Smoothed != nil {
+		return *d.Smoothed
+	}
+	return d.Sentiment
 }
 
 func main() {
-	if len(os.Args) != 3 && len(os.Args) != 4 {
+	var characters bool
+	var maxScore int
+	flag.BoolVar(&characters, "characters", false,
+		"read the third column as a character name (implied by the characters style)")
+	flag.IntVar(&maxScore, "scale", 1,
+		"largest sentiment score the model produces (1 for 3-class models, 2 for 5-point)")
+	flag.Usage = func() {
 		fmt.Fprintln(os.Stderr, "Usage:", os.Args[0],
-			"input.csv output.png [style]")
+			"[flags] input.csv output.png [style]")
 		fmt.Fprintln(os.Stderr, "Available styles:")
 		fmt.Fprintln(os.Stderr, " - line")
 		fmt.Fprintln(os.Stderr, " - heat (default)")
+		fmt.Fprintln(os.Stderr, " - emotions")
+		fmt.Fprintln(os.Stderr, " - characters")
+		fmt.Fprintln(os.Stderr, "\nFlags:")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if flag.NArg() != 2 && flag.NArg() != 3 {
+		flag.Usage()
+		os.Exit(1)
+	}
+
+	if maxScore != 1 && maxScore != 2 {
+		fmt.Fprintln(os.Stderr, "Scale must be 1 or 2.")
 		os.Exit(1)
 	}
 
 	style := "heat"
-	if len(os.Args) > StyleArg {
-		style = os.Args[StyleArg]
+	if flag.NArg() > StyleArg {
+		style = flag.Arg(StyleArg)
+	}
+	if style == "characters" {
+		characters = true
 	}
 
-	data := readData()
+	data := readData(characters, maxScore)
 
-	outFile, err := os.Create(os.Args[OutputArg])
+	outFile, err := os.Create(flag.Arg(OutputArg))
 	if err != nil {
 		fmt.Fprintln(os.Stderr, "Failed to create output:", err)
 		os.Exit(1)
@@ -52,9 +99,21 @@ func main() {
 	var img image.Image
 	switch style {
 	case "line":
-		img = lineGraph(data)
+		img = lineGraph(data, maxScore)
 	case "heat":
-		img = heatGraph(data)
+		img = heatGraph(data, maxScore)
+	case "emotions":
+		if len(data) == 0 || data[0].Emotions == nil {
+			fmt.Fprintln(os.Stderr, "Input has no emotion columns.")
+			os.Exit(1)
+		}
+		img = emotionGraph(data)
+	case "characters":
+		if len(data) == 0 || data[0].Character == "" {
+			fmt.Fprintln(os.Stderr, "Input has no character column.")
+			os.Exit(1)
+		}
+		img = characterGraph(data, maxScore)
 	default:
 		fmt.Fprintln(os.Stderr, "Unknown style:", style)
 		os.Exit(1)
@@ -66,8 +125,13 @@ func main() {
 	}
 }
 
-func readData() []*DataPoint {
-	inFile, err := os.Open(os.Args[InputArg])
+// readData reads the data points.
+// If characters is true, a third column holds character
+// names; otherwise, a column after the emotions (if any)
+// holds smoothed sentiment scores.
+// Scores beyond maxScore are rejected.
+func readData(characters bool, maxScore int) []*DataPoint {
+	inFile, err := os.Open(flag.Arg(InputArg))
 	if err != nil {
 		fmt.Fprintln(os.Stderr, "Failed to open input:", err)
 		os.Exit(1)
@@ -75,6 +139,7 @@ func readData() []*DataPoint {
 	defer inFile.Close()
 
 	r := csv.NewReader(inFile)
+	r.FieldsPerRecord = -1
 	records, err := r.ReadAll()
 	if err != nil {
 		fmt.Fprintln(os.Stderr, "Failed to read input:", err)
@@ -83,8 +148,18 @@ func readData() []*DataPoint {
 
 	output := make([]*DataPoint, len(records))
 	for i, record := range records {
-		if len(record) != 2 {
-			fmt.Fprintln(os.Stderr, "Invalid number of columns:", len(record))
+		numEmotions := len(sentigraph.AllEmotions)
+		if characters && len(record) != 3 {
+			fmt.Fprintf(os.Stderr, "Row %d: expected a character column\n", i)
+			os.Exit(1)
+		} else if len(record) != 2 && len(record) != 3 &&
+			len(record) != 2+numEmotions && len(record) != 3+numEmotions {
+			fmt.Fprintf(os.Stderr, "Row %d: invalid number of columns: %d\n",
+				i, len(record))
+			os.Exit(1)
+		} else if i > 0 && len(record) != len(records[0]) {
+			fmt.Fprintf(os.Stderr, "Row %d: has %d columns, but row 0 has %d\n",
+				i, len(record), len(records[0]))
 			os.Exit(1)
 		}
 		pos, err := strconv.ParseFloat(record[0], 64)
@@ -93,23 +168,44 @@ func readData() []*DataPoint {
 				i, record[0])
 			os.Exit(1)
 		}
-		var sent sentigraph.Sentiment
-		switch record[1] {
-		case "0":
-			sent = sentigraph.Neutral
-		case "-1":
-			sent = sentigraph.Negative
-		case "1":
-			sent = sentigraph.Positive
-		default:
+		score, err := strconv.Atoi(record[1])
+		sent, ok := sentigraph.SentimentForScore(score)
+		if err != nil || !ok {
 			fmt.Fprintf(os.Stderr, "Row %d: invalid sentiment: %s\n",
 				i, record[1])
 			os.Exit(1)
+		} else if score > maxScore || -score > maxScore {
+			fmt.Fprintf(os.Stderr, "Row %d: score %d is beyond the scale (see -scale)\n",
+				i, score)
+			os.Exit(1)
 		}
 		output[i] = &DataPoint{
 			Sentiment: sent,
 			Position:  pos,
 		}
+		if characters {
+			output[i].Character = record[2]
+			record = record[:2]
+		} else if len(record) == 3 || len(record) == 3+numEmotions {
+			last := record[len(record)-1]
+			score, err := strconv.Atoi(last)
+			sent, ok := sentigraph.SentimentForScore(score)
+			if err != nil || !ok || score > maxScore || -score > maxScore {
+				fmt.Fprintf(os.Stderr, "Row %d: invalid smoothed sentiment: %s\n",
+					i, last)
+				os.Exit(1)
+			}
+			output[i].Smoothed = &sent
+			record = record[:len(record)-1]
+		}
+		for _, field := range record[2:] {
+			prob, err := strconv.ParseFloat(field, 64)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Row %d: invalid emotion: %s\n", i, field)
+				os.Exit(1)
+			}
+			output[i].Emotions = append(output[i].Emotions, prob)
+		}
 	}
 
 	return output
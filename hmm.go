@@ -0,0 +1,223 @@
+package sentigraph
+
+import (
+	"errors"
+	"math"
+)
+
+// MoodHMMMinEmission is the smallest emission probability
+// used by MoodHMM, so that a single overconfident
+// classification cannot rule out a mood entirely.
+const MoodHMMMinEmission = 1e-6
+
+// A MoodHMM is a hidden Markov model of the mood of a
+// document as it changes from one sentence to the next.
+//
+// The emissions are a classifier's probabilities for each
+// sentence, which are treated as likelihoods of the
+// hidden mood.
+// The mood stays the same with probability Persistence,
+// and otherwise changes to any other mood with equal
+// probability.
+type MoodHMM struct {
+	States []Sentiment
+
+	// Persistence must be in [0, 1).
+	Persistence float64
+}
+
+// errInvalidPersistence is returned when a MoodHMM's
+// persistence is not a valid probability.
+var errInvalidPersistence = errors.New("mood persistence must be in [0, 1)")
+
+// NewMoodHMM creates a MoodHMM whose states are the
+// sentiments which appear in the emissions.
+func NewMoodHMM(emissions []map[Sentiment]float64,
+	persistence float64) (*MoodHMM, error) {
+	if !validPersistence(persistence) {
+		return nil, errInvalidPersistence
+	}
+	present := map[Sentiment]bool{}
+	for _, e := range emissions {
+		for sent := range e {
+			present[sent] = true
+		}
+	}
+	res := &MoodHMM{Persistence: persistence}
+	for _, sent := range FineSentiments {
+		if present[sent] {
+			res.States = append(res.States, sent)
+		}
+	}
+	return res, nil
+}
+
+// Fit learns the persistence from the emissions using
+// the Baum-Welch algorithm, starting from the current
+// persistence.
+func (m *MoodHMM) Fit(emissions []map[Sentiment]float64, iters int) error {
+	if !validPersistence(m.Persistence) {
+		return errInvalidPersistence
+	}
+	if len(emissions) < 2 || len(m.States) < 2 {
+		return nil
+	}
+	for i := 0; i < iters; i++ {
+		_, stays := m.forwardBackward(emissions)
+		m.Persistence = math.Max(1e-3, math.Min(1-1e-6,
+			stays/float64(len(emissions)-1)))
+	}
+	return nil
+}
+
+// Posteriors computes the probability of each mood for
+// each sentence, given all of the emissions, using the
+// forward-backward algorithm.
+func (m *MoodHMM) Posteriors(emissions []map[Sentiment]float64) ([]map[Sentiment]float64,
+	error) {
+	if !validPersistence(m.Persistence) {
+		return nil, errInvalidPersistence
+	}
+	gammas, _ := m.forwardBackward(emissions)
+	res := make([]map[Sentiment]float64, len(gammas))
+	for t, gamma := range gammas {
+		res[t] = map[Sentiment]float64{}
+		for i, state := range m.States {
+			res[t][state] = gamma[i]
+		}
+	}
+	return res, nil
+}
+
+// Viterbi computes the most likely sequence of moods.
+func (m *MoodHMM) Viterbi(emissions []map[Sentiment]float64) ([]Sentiment, error) {
+	if !validPersistence(m.Persistence) {
+		return nil, errInvalidPersistence
+	}
+	if len(emissions) == 0 || len(m.States) == 0 {
+		return nil, nil
+	}
+	numStates := len(m.States)
+	scores := m.logEmissions(emissions[0])
+	backPointers := make([][]int, len(emissions))
+	for t := 1; t < len(emissions); t++ {
+		backPointers[t] = make([]int, numStates)
+		newScores := m.logEmissions(emissions[t])
+		for j := range newScores {
+			best := math.Inf(-1)
+			for i, score := range scores {
+				score += math.Log(m.transition(i, j))
+				if score > best {
+					best = score
+					backPointers[t][j] = i
+				}
+			}
+			newScores[j] += best
+		}
+		scores = newScores
+	}
+
+	var state int
+	for i, score := range scores {
+		if score > scores[state] {
+			state = i
+		}
+	}
+	res := make([]Sentiment, len(emissions))
+	for t := len(emissions) - 1; t >= 0; t-- {
+		res[t] = m.States[state]
+		if t > 0 {
+			state = backPointers[t][state]
+		}
+	}
+	return res, nil
+}
+
+// forwardBackward computes the posterior probability of
+// each state at each time, along with the expected number
+// of transitions in which the state stays the same.
+func (m *MoodHMM) forwardBackward(emissions []map[Sentiment]float64) ([][]float64, float64) {
+	numStates := len(m.States)
+	probs := make([][]float64, len(emissions))
+	for t, e := range emissions {
+		probs[t] = make([]float64, numStates)
+		for i, state := range m.States {
+			probs[t][i] = math.Max(e[state], MoodHMMMinEmission)
+		}
+	}
+
+	// The forward and backward variables are scaled at
+	// each step to avoid underflow.
+	alphas := make([][]float64, len(emissions))
+	scales := make([]float64, len(emissions))
+	for t := range emissions {
+		alpha := make([]float64, numStates)
+		for j := range alpha {
+			if t == 0 {
+				alpha[j] = 1 / float64(numStates)
+			} else {
+				for i, prev := range alphas[t-1] {
+					alpha[j] += prev * m.transition(i, j)
+				}
+			}
+			alpha[j] *= probs[t][j]
+			scales[t] += alpha[j]
+		}
+		for j := range alpha {
+			alpha[j] /= scales[t]
+		}
+		alphas[t] = alpha
+	}
+
+	betas := make([][]float64, len(emissions))
+	var stays float64
+	for t := len(emissions) - 1; t >= 0; t-- {
+		beta := make([]float64, numStates)
+		for i := range beta {
+			if t == len(emissions)-1 {
+				beta[i] = 1
+				continue
+			}
+			for j, next := range betas[t+1] {
+				beta[i] += m.transition(i, j) * probs[t+1][j] * next
+			}
+			beta[i] /= scales[t+1]
+			stays += alphas[t][i] * m.transition(i, i) * probs[t+1][i] *
+				betas[t+1][i] / scales[t+1]
+		}
+		betas[t] = beta
+	}
+
+	gammas := make([][]float64, len(emissions))
+	for t := range gammas {
+		gammas[t] = make([]float64, numStates)
+		var total float64
+		for i := range gammas[t] {
+			gammas[t][i] = alphas[t][i] * betas[t][i]
+			total += gammas[t][i]
+		}
+		for i := range gammas[t] {
+			gammas[t][i] /= total
+		}
+	}
+	return gammas, stays
+}
+
+func (m *MoodHMM) transition(i, j int) float64 {
+	if i == j {
+		return m.Persistence
+	}
+	return (1 - m.Persistence) / float64(len(m.States)-1)
+}
+
+func (m *MoodHMM) logEmissions(e map[Sentiment]float64) []float64 {
+	res := make([]float64, len(m.States))
+	for i, state := range m.States {
+		res[i] = math.Log(math.Max(e[state], MoodHMMMinEmission))
+	}
+	return res
+}
+
+func validPersistence(p float64) bool {
+	return p >= 0 && p < 1
+}
@@ -0,0 +1,282 @@
+package sentigraph
+
+import (
+	"encoding/json"
+	"log"
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/unixpickle/serializer"
+)
+
+// KNNDefaultK is the number of neighbors used by a KNN
+// model whose K is 0.
+const KNNDefaultK = 10
+
+// KNNMaxDocFraction is the maximum fraction of training
+// documents that a term may appear in for it to be used,
+// unless it appears in fewer than KNNMaxDocMin documents.
+// Extremely common terms carry little weight, but their
+// long posting lists would make searches slow.
+const (
+	KNNMaxDocFraction = 0.1
+	KNNMaxDocMin      = 1000
+)
+
+func init() {
+	var k KNN
+	serializer.RegisterTypedDeserializer(k.SerializerType(), DeserializeKNN)
+}
+
+// KNN classifies text by a weighted vote of the training
+// samples whose TF-IDF vectors are most similar to it
+// (by cosine similarity).
+//
+// Since every prediction is based on specific training
+// samples, those samples can be used to explain the
+// prediction.
+type KNN struct {
+	// Bigraph is true if bigraphs should be used in
+	// addition to unigraphs.
+	Bigraph bool
+
+	// K is the number of neighbors which vote.
+	K int
+
+	// Texts and Sentiments store the training samples.
+	// The model's index is rebuilt from them when the
+	// model is deserialized.
+	Texts      []string
+	Sentiments []Sentiment
+
+	// Weights stores the training weight of each sample.
+	// It is nil if every sample has a weight of 1.
+	Weights []float64 `json:",omitempty"`
+
+	idf   map[string]float64
+	index map[string][]knnPosting
+}
+
+// A Neighbor is a training sample which is similar to a
+// piece of text.
+type Neighbor struct {
+	Text       string
+	Sentiment  Sentiment
+	Similarity float64
+}
+
+type knnPosting struct {
+	doc    int
+	weight float64
+}
+
+// DeserializeKNN deserializes a KNN model.
+func DeserializeKNN(d []byte) (*KNN, error) {
+	var res KNN
+	if err := json.Unmarshal(d, &res); err != nil {
+		return nil, err
+	}
+	res.buildIndex()
+	return &res, nil
+}
+
+// Classify returns the sentiment with the most votes.
+func (k *KNN) Classify(text string) Sentiment {
+	best, _ := Confidence(k.Probabilities(text))
+	return best
+}
+
+// Probabilities returns the fraction of the (similarity
+// weighted) votes for each sentiment.
+// If the text resembles no training sample, every
+// sentiment in the training data is equally likely.
+func (k *KNN) Probabilities(text string) map[Sentiment]float64 {
+	res := map[Sentiment]float64{}
+	var total float64
+	for _, n := range k.neighbors(text) {
+		vote := n.similarity
+		if k.Weights != nil {
+			vote *= k.Weights[n.doc]
+		}
+		res[k.Sentiments[n.doc]] += vote
+		total += vote
+	}
+	if total == 0 {
+		for _, sent := range k.Sentiments {
+			res[sent] = 1
+		}
+		total = float64(len(res))
+	}
+	for sent := range res {
+		res[sent] /= total
+	}
+	return res
+}
+
+// Neighbors returns the training samples which voted on
+// the classification of the text, from most to least
+// similar.
+func (k *KNN) Neighbors(text string) []*Neighbor {
+	var res []*Neighbor
+	for _, n := range k.neighbors(text) {
+		res = append(res, &Neighbor{
+			Text:       k.Texts[n.doc],
+			Sentiment:  k.Sentiments[n.doc],
+			Similarity: n.similarity,
+		})
+	}
+	return res
+}
+
+// TopFeatures explains a classification by returning the
+// texts of up to n of the nearest neighbors which have the
+// favored sentiment, from most to least similar.
+// Since every neighbor votes for its own sentiment, the
+// other sentiment does not affect the result.
+func (k *KNN) TopFeatures(text string, favored, other Sentiment, n int) []string {
+	var res []string
+	for _, match := range k.neighbors(text) {
+		if len(res) == n {
+			break
+		}
+		if k.Sentiments[match.doc] == favored {
+			res = append(res, k.Texts[match.doc])
+		}
+	}
+	return res
+}
+
+// Train stores the samples and indexes them.
+func (k *KNN) Train(s []*Sample) {
+	k.Texts = nil
+	k.Sentiments = nil
+	k.Weights = nil
+	k.Update(s)
+}
+
+// Update adds more samples to the model.
+func (k *KNN) Update(s []*Sample) error {
+	for _, sample := range s {
+		if sample.TrainingWeight() != 1 && k.Weights == nil {
+			k.Weights = make([]float64, len(k.Texts))
+			for i := range k.Weights {
+				k.Weights[i] = 1
+			}
+		}
+		k.Texts = append(k.Texts, sample.Contents)
+		k.Sentiments = append(k.Sentiments, sample.Sentiment)
+		if k.Weights != nil {
+			k.Weights = append(k.Weights, sample.TrainingWeight())
+		}
+	}
+	log.Println("Indexing", len(k.Texts), "samples...")
+	k.buildIndex()
+	return nil
+}
+
+// SerializerType gives the unique ID used to serialize
+// KNN models with the serializer package.
+func (k *KNN) SerializerType() string {
+	return "github.com/unixpickle/sentigraph.KNN"
+}
+
+// Serialize serializes the model.
+func (k *KNN) Serialize() ([]byte, error) {
+	return json.Marshal(k)
+}
+
+type knnMatch struct {
+	doc        int
+	similarity float64
+}
+
+// neighbors finds the k most similar training samples.
+func (k *KNN) neighbors(text string) []knnMatch {
+	scores := map[int]float64{}
+	for term, weight := range k.vector(text) {
+		for _, posting := range k.index[term] {
+			scores[posting.doc] += weight * posting.weight
+		}
+	}
+	matches := make([]knnMatch, 0, len(scores))
+	for doc, score := range scores {
+		matches = append(matches, knnMatch{doc: doc, similarity: score})
+	}
+	sort.Slice(matches, func(i, j int) bool {
+		if matches[i].similarity != matches[j].similarity {
+			return matches[i].similarity > matches[j].similarity
+		}
+		return matches[i].doc < matches[j].doc
+	})
+	count := k.K
+	if count == 0 {
+		count = KNNDefaultK
+	}
+	if len(matches) > count {
+		matches = matches[:count]
+	}
+	return matches
+}
+
+// buildIndex computes the IDF of each term and the
+// inverted index from the training samples.
+func (k *KNN) buildIndex() {
+	docFreqs := map[string]int{}
+	for _, text := range k.Texts {
+		for term := range k.termCounts(text) {
+			docFreqs[term]++
+		}
+	}
+
+	maxDocs := int(KNNMaxDocFraction * float64(len(k.Texts)))
+	if maxDocs < KNNMaxDocMin {
+		maxDocs = KNNMaxDocMin
+	}
+	k.idf = map[string]float64{}
+	for term, freq := range docFreqs {
+		if freq <= maxDocs {
+			k.idf[term] = math.Log(float64(len(k.Texts)) / float64(freq))
+		}
+	}
+
+	k.index = map[string][]knnPosting{}
+	for doc, text := range k.Texts {
+		for term, weight := range k.vector(text) {
+			k.index[term] = append(k.index[term], knnPosting{doc: doc, weight: weight})
+		}
+	}
+}
+
+// vector computes the normalized TF-IDF vector of the
+// text, ignoring terms which are not in the index.
+func (k *KNN) vector(text string) map[string]float64 {
+	res := map[string]float64{}
+	var norm float64
+	for term, count := range k.termCounts(text) {
+		idf, ok := k.idf[term]
+		if !ok || idf == 0 {
+			continue
+		}
+		weight := (1 + math.Log(float64(count))) * idf
+		res[term] = weight
+		norm += weight * weight
+	}
+	norm = math.Sqrt(norm)
+	for term := range res {
+		res[term] /= norm
+	}
+	return res
+}
+
+func (k *KNN) termCounts(text string) map[string]int {
+	fields := strings.Fields(SeparatePunctuation(Normalize(text)))
+	res := map[string]int{}
+	for i, f := range fields {
+		res[f]++
+		if i > 0 && k.Bigraph {
+			res[fields[i-1]+" "+f]++
+		}
+	}
+	return res
+}
@@ -0,0 +1,316 @@
+// Command label runs an interactive active learning
+// session, in which the user labels the sentences that a
+// model is least certain about and the model is
+// periodically retrained on the new labels.
+package main
+
+import (
+	"bufio"
+	"encoding/csv"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/unixpickle/sentigraph"
+	"github.com/unixpickle/serializer"
+)
+
+const (
+	ModelArg  = 0
+	TextArg   = 1
+	CorpusArg = 2
+)
+
+func main() {
+	var basePath string
+	var outputPath string
+	var batchSize int
+	var retrainSize int
+	flag.StringVar(&basePath, "base", "",
+		"original training corpus to retrain on along with the new labels "+
+			"(required unless the model can be updated incrementally)")
+	flag.StringVar(&outputPath, "output", "",
+		"path to save the retrained model (by default, it is not saved)")
+	flag.IntVar(&batchSize, "batch", 10,
+		"number of sentences to show between uncertainty updates")
+	flag.IntVar(&retrainSize, "retrain", 50,
+		"number of new labels after which to retrain the model")
+	flag.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage:", os.Args[0],
+			"[flags] model_file unlabelled.txt corpus.csv")
+		fmt.Fprintln(os.Stderr, "\nThe unlabelled file has one sentence per line.",
+			"New labels are appended to the corpus file.")
+		fmt.Fprintln(os.Stderr, "\nWithout -base, the model is updated with each",
+			"session's new labels, so resume a session from the model it saved.")
+		fmt.Fprintln(os.Stderr, "\nFlags:")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if flag.NArg() != 3 {
+		flag.Usage()
+		os.Exit(1)
+	}
+
+	model := readModel()
+	var base []*sentigraph.Sample
+	if basePath != "" {
+		base = readCorpus(basePath, false)
+	} else if incModel, ok := model.(sentigraph.IncrementalModel); !ok {
+		fmt.Fprintf(os.Stderr, "Model cannot be updated incrementally (%T), "+
+			"so -base is required.\n", model)
+		os.Exit(1)
+	} else if err := incModel.Update(nil); err != nil {
+		// Fail now rather than after the user has labelled
+		// a batch of sentences.
+		fmt.Fprintln(os.Stderr, "Model cannot be updated, so -base is required:", err)
+		os.Exit(1)
+	}
+	checkCorpusFormat(flag.Arg(CorpusArg))
+	labelled := readCorpus(flag.Arg(CorpusArg), true)
+	pool := readPool(labelled)
+
+	corpusFile, err := os.OpenFile(flag.Arg(CorpusArg),
+		os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Failed to open corpus:", err)
+		os.Exit(1)
+	}
+	defer corpusFile.Close()
+	corpus := csv.NewWriter(corpusFile)
+
+	input := bufio.NewReader(os.Stdin)
+
+	// pending stores the labels which the model has not
+	// yet learned.
+	var pending []*sentigraph.Sample
+
+	fmt.Println("Label each sentence: p(ositive), n(egative), u (neutral),",
+		"s(kip), or q(uit).")
+	for len(pool) > 0 {
+		rankByUncertainty(model, pool)
+		batch := pool
+		if len(batch) > batchSize {
+			batch = batch[:batchSize]
+		}
+		pool = pool[len(batch):]
+
+		for _, text := range batch {
+			sentiment, ok, quit := prompt(input, model, text)
+			if quit {
+				if len(pending) > 0 && outputPath != "" {
+					retrain(model, base, labelled, pending, outputPath)
+				}
+				return
+			} else if !ok {
+				continue
+			}
+			sample := &sentigraph.Sample{Contents: text, Sentiment: sentiment}
+			labelled = append(labelled, sample)
+			writeSample(corpus, sample, len(labelled))
+			pending = append(pending, sample)
+		}
+
+		if len(pending) >= retrainSize {
+			retrain(model, base, labelled, pending, outputPath)
+			pending = nil
+		}
+	}
+
+	fmt.Println("No unlabelled sentences remain.")
+	if len(pending) > 0 && outputPath != "" {
+		retrain(model, base, labelled, pending, outputPath)
+	}
+}
+
+// prompt asks the user to label a sentence.
+// It returns ok=false if the user skipped the sentence
+// and quit=true if the user wants to stop.
+func prompt(input *bufio.Reader, model sentigraph.ProbabilityModel,
+	text string) (sentiment sentigraph.Sentiment, ok, quit bool) {
+	guess, margin := sentigraph.Confidence(model.Probabilities(text))
+	fmt.Printf("\n%s\n(model guess: %s, margin %.3f) > ", text,
+		sentimentName(guess), margin)
+	for {
+		line, err := input.ReadString('\n')
+		if err != nil {
+			return 0, false, true
+		}
+		switch strings.TrimSpace(strings.ToLower(line)) {
+		case "p":
+			return sentigraph.Positive, true, false
+		case "n":
+			return sentigraph.Negative, true, false
+		case "u":
+			return sentigraph.Neutral, true, false
+		case "s":
+			return 0, false, false
+		case "q":
+			return 0, false, true
+		}
+		fmt.Print("Please enter p, n, u, s, or q > ")
+	}
+}
+
+// rankByUncertainty sorts the texts so that the ones with
+// the smallest confidence margin come first.
+func rankByUncertainty(model sentigraph.ProbabilityModel, texts []string) {
+	margins := map[string]float64{}
+	for _, text := range texts {
+		_, margins[text] = sentigraph.Confidence(model.Probabilities(text))
+	}
+	sort.SliceStable(texts, func(i, j int) bool {
+		return margins[texts[i]] < margins[texts[j]]
+	})
+}
+
+// retrain teaches the model the pending labels.
+// If there is a base corpus, the model is retrained from
+// scratch on it and all of the labels.
+// Otherwise, the model is updated with the pending labels
+// (main checks that it can be).
+func retrain(model sentigraph.Model, base, labelled, pending []*sentigraph.Sample,
+	outputPath string) {
+	if base != nil {
+		samples := append(append([]*sentigraph.Sample{}, base...), labelled...)
+		log.Println("Retraining on", len(samples), "samples...")
+		model.Train(samples)
+	} else {
+		log.Println("Updating model with", len(pending), "new labels...")
+		if err := model.(sentigraph.IncrementalModel).Update(pending); err != nil {
+			fmt.Fprintln(os.Stderr, "Failed to update model:", err)
+			os.Exit(1)
+		}
+	}
+	if outputPath == "" {
+		return
+	}
+	data, err := serializer.SerializeWithType(model)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Failed to serialize model:", err)
+		os.Exit(1)
+	}
+	if err := ioutil.WriteFile(outputPath, data, 0755); err != nil {
+		fmt.Fprintln(os.Stderr, "Failed to write model file:", err)
+		os.Exit(1)
+	}
+}
+
+func writeSample(w *csv.Writer, s *sentigraph.Sample, id int) {
+	label := map[sentigraph.Sentiment]string{
+		sentigraph.Negative: "0",
+		sentigraph.Neutral:  "2",
+		sentigraph.Positive: "4",
+	}[s.Sentiment]
+	w.Write([]string{label, strconv.Itoa(id), "", "NO_QUERY", "", s.Contents})
+	w.Flush()
+	if err := w.Error(); err != nil {
+		fmt.Fprintln(os.Stderr, "Failed to write corpus:", err)
+		os.Exit(1)
+	}
+}
+
+func sentimentName(s sentigraph.Sentiment) string {
+	switch s {
+	case sentigraph.Positive, sentigraph.VeryPositive:
+		return "positive"
+	case sentigraph.Negative, sentigraph.VeryNegative:
+		return "negative"
+	default:
+		return "neutral"
+	}
+}
+
+func readModel() sentigraph.ProbabilityModel {
+	model, err := sentigraph.ReadModel(flag.Arg(ModelArg))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Failed to read model:", err)
+		os.Exit(1)
+	}
+	probModel, ok := model.(sentigraph.ProbabilityModel)
+	if !ok {
+		fmt.Fprintf(os.Stderr, "Model does not support probabilities: %T\n", model)
+		os.Exit(1)
+	}
+	return probModel
+}
+
+// readCorpus reads a corpus.
+// If optional is true, a missing file is treated as an
+// empty corpus.
+func readCorpus(path string, optional bool) []*sentigraph.Sample {
+	f, err := os.Open(path)
+	if optional && os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		fmt.Fprintln(os.Stderr, "Failed to open corpus:", err)
+		os.Exit(1)
+	}
+	defer f.Close()
+	samples, err := sentigraph.ReadSamples(f)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Failed to parse corpus:", err)
+		os.Exit(1)
+	}
+	return samples
+}
+
+// checkCorpusFormat makes sure that an existing corpus
+// file is in the sentiment140 format, since that is the
+// format in which new labels are appended.
+func checkCorpusFormat(path string) {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return
+	} else if err != nil {
+		fmt.Fprintln(os.Stderr, "Failed to open corpus:", err)
+		os.Exit(1)
+	}
+	defer f.Close()
+	first, err := csv.NewReader(f).Read()
+	if err == io.EOF {
+		return
+	} else if err != nil {
+		fmt.Fprintln(os.Stderr, "Failed to read corpus:", err)
+		os.Exit(1)
+	}
+	if len(first) != 6 || (first[0] != "0" && first[0] != "2" && first[0] != "4") {
+		fmt.Fprintln(os.Stderr, "Corpus must be in the sentiment140 format.")
+		os.Exit(1)
+	}
+}
+
+// readPool reads the unlabelled sentences, excluding ones
+// which have already been labelled.
+func readPool(labelled []*sentigraph.Sample) []string {
+	done := map[string]bool{}
+	for _, s := range labelled {
+		done[s.Contents] = true
+	}
+	f, err := os.Open(flag.Arg(TextArg))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Failed to open unlabelled text:", err)
+		os.Exit(1)
+	}
+	defer f.Close()
+	var res []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		text := strings.TrimSpace(scanner.Text())
+		if text != "" && !done[text] {
+			done[text] = true
+			res = append(res, text)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		fmt.Fprintln(os.Stderr, "Failed to read unlabelled text:", err)
+		os.Exit(1)
+	}
+	return res
+}
@@ -0,0 +1,56 @@
+// Command merge combines Bayes models which were trained
+// on different shards of a corpus into a single model.
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"github.com/unixpickle/sentigraph"
+	"github.com/unixpickle/serializer"
+)
+
+const (
+	OutputArg = 1
+	InputArg  = 2
+)
+
+func main() {
+	if len(os.Args) < 4 {
+		fmt.Fprintln(os.Stderr, "Usage:", os.Args[0],
+			"output_model input_model1 input_model2 ...")
+		os.Exit(1)
+	}
+
+	var models []*sentigraph.Bayes
+	for _, path := range os.Args[InputArg:] {
+		model, err := sentigraph.ReadModel(path)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "Failed to read model:", err)
+			os.Exit(1)
+		}
+		bayes, ok := model.(*sentigraph.Bayes)
+		if !ok {
+			fmt.Fprintf(os.Stderr, "Cannot merge %s: not a Bayes model\n", path)
+			os.Exit(1)
+		}
+		models = append(models, bayes)
+	}
+
+	merged, err := sentigraph.MergeBayes(models...)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Failed to merge models:", err)
+		os.Exit(1)
+	}
+
+	data, err := serializer.SerializeWithType(merged)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Failed to serialize model:", err)
+		os.Exit(1)
+	}
+	if err := ioutil.WriteFile(os.Args[OutputArg], data, 0755); err != nil {
+		fmt.Fprintln(os.Stderr, "Failed to write model file:", err)
+		os.Exit(1)
+	}
+}
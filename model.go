@@ -1,8 +1,12 @@
 package sentigraph
 
 import (
+	"context"
 	"fmt"
 	"io/ioutil"
+	"log"
+	"math"
+	"time"
 
 	"github.com/unixpickle/serializer"
 )
@@ -23,6 +27,136 @@ type Model interface {
 	Train(samples []*Sample)
 }
 
+// TrainProgress describes how far training has come.
+type TrainProgress struct {
+	// Stage names the units of Done and Total, such as
+	// "samples", "trees", or "epochs".
+	Stage string
+
+	Done  int
+	Total int
+
+	// Loss is the most recent training loss, for models
+	// which minimize one.
+	// It is 0 for other models.
+	Loss float64
+}
+
+// A ProgressFunc is called periodically during training.
+// It may be called from any goroutine, but never from
+// more than one at once.
+type ProgressFunc func(p TrainProgress)
+
+// A ContextModel is a Model whose training can be
+// monitored and cancelled.
+type ContextModel interface {
+	Model
+
+	// TrainContext is like Train, but it reports progress
+	// to the (possibly nil) progress function and stops
+	// early if the context is cancelled.
+	//
+	// When cancelled, it returns the context's error and
+	// leaves the model in a usable state reflecting the
+	// training done so far, so that it can be saved as a
+	// checkpoint.
+	TrainContext(ctx context.Context, samples []*Sample, progress ProgressFunc) error
+}
+
+// A RandomModel is a Model whose training involves
+// randomness.
+// Training a RandomModel twice with the same seed and
+// samples produces identical models.
+type RandomModel interface {
+	Model
+
+	// SetSeed sets the seed for training.
+	// The seed is saved with the model.
+	SetSeed(seed int64)
+}
+
+// A VectorModel is a Model which can use pretrained word
+// vectors as features.
+type VectorModel interface {
+	Model
+
+	// SetWordVectors sets the vectors to use in the next
+	// call to Train.
+	// The vectors are saved with the model.
+	SetWordVectors(w *WordVectors)
+}
+
+// NewSeed generates a random, non-zero seed.
+// The seed is logged, so that a run which picked its
+// seed at random can be reproduced.
+func NewSeed() int64 {
+	seed := time.Now().UnixNano()
+	if seed == 0 {
+		seed = 1
+	}
+	log.Println("Using random seed", seed)
+	return seed
+}
+
+// An IncrementalModel is a Model which can learn from new
+// samples without forgetting what it learned during
+// previous training.
+type IncrementalModel interface {
+	Model
+
+	// Update trains the model further on the samples.
+	// The model must already have been trained, and it
+	// may return an error if it cannot be updated (e.g.
+	// because it was saved by an older version).
+	Update(samples []*Sample) error
+}
+
+// A ProbabilityModel is a Model which can estimate how
+// likely each sentiment is, rather than just picking the
+// most likely one.
+type ProbabilityModel interface {
+	Model
+
+	// Probabilities returns the probability of each
+	// sentiment given the text.
+	// The probabilities sum to 1.
+	Probabilities(text string) map[Sentiment]float64
+}
+
+// A FeatureModel is a Model which can explain its
+// classifications in terms of the features of the text.
+type FeatureModel interface {
+	Model
+
+	// TopFeatures returns up to n features of the text
+	// which most favor one sentiment over another, from
+	// most to least influential.
+	TopFeatures(text string, favored, other Sentiment, n int) []string
+}
+
+// Confidence returns the most likely sentiment from a
+// probability distribution, along with the margin by
+// which its probability exceeds that of the runner-up.
+// A small margin indicates an uncertain classification.
+func Confidence(probs map[Sentiment]float64) (Sentiment, float64) {
+	var best Sentiment
+	bestProb := -1.0
+	secondProb := 0.0
+	for _, sentiment := range FineSentiments {
+		prob, ok := probs[sentiment]
+		if !ok {
+			continue
+		}
+		if prob > bestProb {
+			secondProb = math.Max(bestProb, 0)
+			best, bestProb = sentiment, prob
+		} else if prob > secondProb {
+			secondProb = prob
+		}
+	}
+	return best, bestProb - secondProb
+}
+
 // ReadModel reads a model from a file.
 func ReadModel(path string) (Model, error) {
 	modelData, err := ioutil.ReadFile(path)
@@ -49,6 +183,30 @@ var Models = map[string]func() Model{
 	"forestBigraph": func() Model {
 		return &Forest{Bigraph: true}
 	},
+	"randomForest": func() Model {
+		return &Forest{MinLeaf: 2, SplitFeatures: ForestSqrtFeatures}
+	},
+	"randomForestBigraph": func() Model {
+		return &Forest{Bigraph: true, MinLeaf: 2, SplitFeatures: ForestSqrtFeatures}
+	},
+	"boost": func() Model {
+		return &Boost{}
+	},
+	"boostBigraph": func() Model {
+		return &Boost{Bigraph: true}
+	},
+	"knn": func() Model {
+		return &KNN{}
+	},
+	"knnBigraph": func() Model {
+		return &KNN{Bigraph: true}
+	},
+	"neural": func() Model {
+		return &Neural{}
+	},
+	"neuralBigraph": func() Model {
+		return &Neural{Bigraph: true}
+	},
 	"bayes": func() Model {
 		return &Bayes{}
 	},
@@ -0,0 +1,460 @@
+package sentigraph
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"hash/fnv"
+	"log"
+	"math"
+	"math/rand"
+	"strings"
+
+	"github.com/unixpickle/serializer"
+)
+
+// These environment variables override the corresponding
+// Neural hyperparameters during training.
+const (
+	NeuralEpochsEnvVar       = "NEURAL_EPOCHS"
+	NeuralLearningRateEnvVar = "NEURAL_LEARNING_RATE"
+	NeuralDimEnvVar          = "NEURAL_DIM"
+	NeuralHiddenEnvVar       = "NEURAL_HIDDEN"
+)
+
+// Default Neural hyperparameters, used when the
+// corresponding fields are 0.
+const (
+	NeuralDefaultBuckets      = 1 << 18
+	NeuralDefaultDim          = 16
+	NeuralDefaultHidden       = 16
+	NeuralDefaultEpochs       = 5
+	NeuralDefaultLearningRate = 0.1
+)
+
+func init() {
+	var n Neural
+	serializer.RegisterTypedDeserializer(n.SerializerType(), DeserializeNeural)
+}
+
+// Neural is a small feed-forward network in the style of
+// fastText.
+// The embeddings of a text's words (and, optionally,
+// bigraphs) are averaged, passed through one hidden
+// layer, and then through a softmax layer.
+//
+// To keep the model small, features are hashed into a
+// fixed number of embedding buckets.
+//
+// If the model has pretrained word vectors, the average
+// of the text's word vectors is fed to the hidden layer
+// alongside the learned embeddings.
+// The pretrained vectors are not changed by training.
+type Neural struct {
+	// Bigraph is true if bigraphs should be used in
+	// addition to unigraphs.
+	Bigraph bool
+
+	// Buckets is the number of embedding vectors.
+	Buckets int
+
+	// Dim is the dimensionality of the embeddings.
+	Dim int
+
+	// Hidden is the number of hidden units.
+	Hidden int
+
+	// Epochs is the number of passes over the training
+	// data.
+	Epochs int
+
+	// LearningRate is the initial step size for SGD.
+	// It decays linearly to 0 over the course of training.
+	LearningRate float64
+
+	// Seed seeds the random number generator used for
+	// initialization and shuffling.
+	// If it is 0 when training begins, a seed is chosen
+	// at random and stored here.
+	Seed int64
+
+	// Classes lists the sentiments in the training data,
+	// in the order of the outputs.
+	Classes []Sentiment
+
+	// Vectors stores optional pretrained word vectors.
+	Vectors *WordVectors `json:",omitempty"`
+
+	Embeddings    packedFloats
+	HiddenWeights packedFloats
+	HiddenBiases  packedFloats
+	OutputWeights packedFloats
+	OutputBiases  packedFloats
+}
+
+// DeserializeNeural deserializes a Neural model.
+func DeserializeNeural(d []byte) (*Neural, error) {
+	var res Neural
+	if err := json.Unmarshal(d, &res); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+// Classify returns the most likely sentiment.
+func (n *Neural) Classify(text string) Sentiment {
+	best, _ := Confidence(n.Probabilities(text))
+	return best
+}
+
+// Probabilities returns the network's output
+// distribution.
+func (n *Neural) Probabilities(text string) map[Sentiment]float64 {
+	var act neuralActivations
+	n.forward(n.buckets(text), n.pretrained(text), &act)
+	res := map[Sentiment]float64{}
+	for i, class := range n.Classes {
+		res[class] = act.probs[i]
+	}
+	return res
+}
+
+// Train initializes the network and trains it on the
+// samples.
+func (n *Neural) Train(s []*Sample) {
+	n.TrainContext(context.Background(), s, nil)
+}
+
+// TrainContext is like Train, but it reports the progress
+// and loss of each epoch, and it can be cancelled.
+// If it is cancelled, the network keeps the weights it
+// had reached.
+func (n *Neural) TrainContext(ctx context.Context, s []*Sample, p ProgressFunc) error {
+	n.setDefaults()
+	if n.Seed == 0 {
+		n.Seed = NewSeed()
+	}
+	r := rand.New(rand.NewSource(n.Seed))
+
+	n.Classes = nil
+	for _, sent := range FineSentiments {
+		for _, sample := range s {
+			if sample.Sentiment == sent {
+				n.Classes = append(n.Classes, sent)
+				break
+			}
+		}
+	}
+	if n.Vectors != nil {
+		n.Vectors.ResetKept()
+	}
+	if n.Vectors != nil && n.Vectors.TFIDF {
+		log.Println("Computing IDF for word vectors...")
+		texts := make([]string, len(s))
+		for i, sample := range s {
+			texts[i] = sample.Contents
+		}
+		n.Vectors.FitIDF(texts)
+	}
+	n.initWeights(r)
+	return n.sgd(ctx, s, r, p)
+}
+
+// Update trains the network further on the samples,
+// using the same number of epochs as the original
+// training.
+// The samples may not contain sentiments which were not
+// in the original training data.
+func (n *Neural) Update(s []*Sample) error {
+	if n.Embeddings == nil {
+		return errors.New("neural model has not been trained")
+	}
+	for _, sample := range s {
+		if n.classIndex(sample.Sentiment) < 0 {
+			return errors.New("neural model cannot learn new sentiments")
+		}
+	}
+	return n.sgd(context.Background(), s, rand.New(rand.NewSource(n.Seed)), nil)
+}
+
+// SetSeed sets n.Seed.
+func (n *Neural) SetSeed(seed int64) {
+	n.Seed = seed
+}
+
+// SetWordVectors sets n.Vectors.
+func (n *Neural) SetWordVectors(w *WordVectors) {
+	n.Vectors = w
+}
+
+// SerializerType gives the unique ID used to serialize
+// Neural models with the serializer package.
+func (n *Neural) SerializerType() string {
+	return "github.com/unixpickle/sentigraph.Neural"
+}
+
+// Serialize serializes the model.
+func (n *Neural) Serialize() ([]byte, error) {
+	return json.Marshal(n)
+}
+
+func (n *Neural) setDefaults() {
+	if n.Buckets == 0 {
+		n.Buckets = NeuralDefaultBuckets
+	}
+	if n.Dim == 0 {
+		n.Dim = NeuralDefaultDim
+	}
+	if n.Hidden == 0 {
+		n.Hidden = NeuralDefaultHidden
+	}
+	if n.Epochs == 0 {
+		n.Epochs = NeuralDefaultEpochs
+	}
+	if n.LearningRate == 0 {
+		n.LearningRate = NeuralDefaultLearningRate
+	}
+	envInt(NeuralEpochsEnvVar, &n.Epochs)
+	envFloat(NeuralLearningRateEnvVar, &n.LearningRate)
+	envInt(NeuralDimEnvVar, &n.Dim)
+	envInt(NeuralHiddenEnvVar, &n.Hidden)
+}
+
+func (n *Neural) initWeights(r *rand.Rand) {
+	uniform := func(size int, scale float64) packedFloats {
+		res := make(packedFloats, size)
+		for i := range res {
+			res[i] = float32((r.Float64()*2 - 1) * scale)
+		}
+		return res
+	}
+	n.Embeddings = uniform(n.Buckets*n.Dim, 1/float64(n.Dim))
+	inputs := n.inputSize()
+	n.HiddenWeights = uniform(n.Hidden*inputs, math.Sqrt(6/float64(n.Hidden+inputs)))
+	n.HiddenBiases = make(packedFloats, n.Hidden)
+	n.OutputWeights = uniform(len(n.Classes)*n.Hidden,
+		math.Sqrt(6/float64(len(n.Classes)+n.Hidden)))
+	n.OutputBiases = make(packedFloats, len(n.Classes))
+}
+
+// sgd runs stochastic gradient descent for n.Epochs
+// passes over the samples.
+func (n *Neural) sgd(ctx context.Context, s []*Sample, r *rand.Rand, p ProgressFunc) error {
+	log.Println("Hashing features...")
+	buckets := make([][]int, len(s))
+	pretrained := make([][]float64, len(s))
+	labels := make([]int, len(s))
+	for i, sample := range s {
+		buckets[i] = n.buckets(sample.Contents)
+		pretrained[i] = n.pretrained(sample.Contents)
+		if n.Vectors != nil {
+			n.Vectors.Keep(sample.Contents)
+		}
+		labels[i] = n.classIndex(sample.Sentiment)
+	}
+
+	var act neuralActivations
+	totalSteps := float64(n.Epochs * len(s))
+	var step int
+	for epoch := 0; epoch < n.Epochs; epoch++ {
+		var loss, totalWeight float64
+		for _, i := range r.Perm(len(s)) {
+			if step%1000 == 0 {
+				if err := ctx.Err(); err != nil {
+					return err
+				}
+			}
+			rate := n.LearningRate * (1 - float64(step)/totalSteps)
+			weight := s[i].TrainingWeight()
+			loss += weight * n.step(buckets[i], pretrained[i], labels[i], rate*weight, &act)
+			totalWeight += weight
+			step++
+		}
+		if p != nil {
+			p(TrainProgress{Stage: "epochs", Done: epoch + 1, Total: n.Epochs,
+				Loss: loss / totalWeight})
+		}
+	}
+	return nil
+}
+
+// neuralActivations stores the results of a forward pass.
+// The input consists of the averaged learned embeddings,
+// followed by the pretrained vector (if there is one).
+type neuralActivations struct {
+	input  []float64
+	hidden []float64
+	probs  []float64
+}
+
+func (n *Neural) forward(buckets []int, pretrained []float64, act *neuralActivations) {
+	inputs := n.inputSize()
+	act.input = resizeFloats(act.input, inputs)
+	for _, b := range buckets {
+		vec := n.Embeddings[b*n.Dim : (b+1)*n.Dim]
+		for i, x := range vec {
+			act.input[i] += float64(x)
+		}
+	}
+	if len(buckets) > 0 {
+		for i := 0; i < n.Dim; i++ {
+			act.input[i] /= float64(len(buckets))
+		}
+	}
+	copy(act.input[n.Dim:], pretrained)
+
+	act.hidden = resizeFloats(act.hidden, n.Hidden)
+	for i := range act.hidden {
+		sum := float64(n.HiddenBiases[i])
+		row := n.HiddenWeights[i*inputs : (i+1)*inputs]
+		for j, w := range row {
+			sum += float64(w) * act.input[j]
+		}
+		act.hidden[i] = math.Tanh(sum)
+	}
+
+	logits := make([]float64, len(n.Classes))
+	for i := range logits {
+		sum := float64(n.OutputBiases[i])
+		row := n.OutputWeights[i*n.Hidden : (i+1)*n.Hidden]
+		for j, w := range row {
+			sum += float64(w) * act.hidden[j]
+		}
+		logits[i] = sum
+	}
+	act.probs = softmax(logits)
+}
+
+// step performs one SGD step and returns the sample's
+// cross-entropy loss before the step.
+func (n *Neural) step(buckets []int, pretrained []float64, label int, rate float64,
+	act *neuralActivations) float64 {
+	n.forward(buckets, pretrained, act)
+	loss := -math.Log(math.Max(act.probs[label], 1e-12))
+
+	hiddenGrad := make([]float64, n.Hidden)
+	for i, prob := range act.probs {
+		outGrad := prob
+		if i == label {
+			outGrad--
+		}
+		row := n.OutputWeights[i*n.Hidden : (i+1)*n.Hidden]
+		for j, w := range row {
+			hiddenGrad[j] += outGrad * float64(w)
+			row[j] -= float32(rate * outGrad * act.hidden[j])
+		}
+		n.OutputBiases[i] -= float32(rate * outGrad)
+	}
+
+	inputs := n.inputSize()
+	embeddingGrad := make([]float64, n.Dim)
+	for i, h := range act.hidden {
+		preGrad := hiddenGrad[i] * (1 - h*h)
+		row := n.HiddenWeights[i*inputs : (i+1)*inputs]
+		for j, w := range row {
+			if j < n.Dim {
+				embeddingGrad[j] += preGrad * float64(w)
+			}
+			row[j] -= float32(rate * preGrad * act.input[j])
+		}
+		n.HiddenBiases[i] -= float32(rate * preGrad)
+	}
+
+	if len(buckets) > 0 {
+		scale := rate / float64(len(buckets))
+		for _, b := range buckets {
+			vec := n.Embeddings[b*n.Dim : (b+1)*n.Dim]
+			for j, g := range embeddingGrad {
+				vec[j] -= float32(scale * g)
+			}
+		}
+	}
+
+	return loss
+}
+
+// pretrained computes the pretrained vector features of
+// the text, or returns nil if there are no pretrained
+// vectors.
+func (n *Neural) pretrained(text string) []float64 {
+	if n.Vectors == nil {
+		return nil
+	}
+	return n.Vectors.Embed(text)
+}
+
+func (n *Neural) inputSize() int {
+	if n.Vectors == nil {
+		return n.Dim
+	}
+	return n.Dim + n.Vectors.Dim
+}
+
+// buckets hashes the features of the text to embedding
+// indices.
+func (n *Neural) buckets(text string) []int {
+	fields := strings.Fields(SeparatePunctuation(Normalize(text)))
+	var res []int
+	for i, f := range fields {
+		res = append(res, n.bucket(f))
+		if i > 0 && n.Bigraph {
+			res = append(res, n.bucket(fields[i-1]+" "+f))
+		}
+	}
+	return res
+}
+
+func (n *Neural) bucket(feature string) int {
+	h := fnv.New32a()
+	h.Write([]byte(feature))
+	return int(h.Sum32() % uint32(n.Buckets))
+}
+
+func (n *Neural) classIndex(s Sentiment) int {
+	for i, class := range n.Classes {
+		if class == s {
+			return i
+		}
+	}
+	return -1
+}
+
+func resizeFloats(f []float64, size int) []float64 {
+	if cap(f) < size {
+		return make([]float64, size)
+	}
+	f = f[:size]
+	for i := range f {
+		f[i] = 0
+	}
+	return f
+}
+
+// packedFloats is a vector which is serialized to JSON as
+// base64-encoded little-endian binary, which is much more
+// compact than a list of numbers.
+type packedFloats []float32
+
+func (p packedFloats) MarshalJSON() ([]byte, error) {
+	data := make([]byte, 4*len(p))
+	for i, x := range p {
+		binary.LittleEndian.PutUint32(data[4*i:], math.Float32bits(x))
+	}
+	return json.Marshal(data)
+}
+
+func (p *packedFloats) UnmarshalJSON(d []byte) error {
+	var data []byte
+	if err := json.Unmarshal(d, &data); err != nil {
+		return err
+	}
+	if len(data)%4 != 0 {
+		return errors.New("invalid packed float length")
+	}
+	*p = make(packedFloats, len(data)/4)
+	for i := range *p {
+		(*p)[i] = math.Float32frombits(binary.LittleEndian.Uint32(data[4*i:]))
+	}
+	return nil
+}
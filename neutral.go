@@ -0,0 +1,147 @@
+package sentigraph
+
+import (
+	"errors"
+	"sort"
+
+	"github.com/unixpickle/serializer"
+)
+
+func init() {
+	var n NeutralBand
+	serializer.RegisterTypedDeserializer(n.SerializerType(), DeserializeNeutralBand)
+}
+
+// NeutralBand wraps a model so that it classifies text as
+// Neutral whenever the model is not confident.
+//
+// This lets a model which was only trained on positive
+// and negative samples recognize neutral text.
+type NeutralBand struct {
+	Model ProbabilityModel
+
+	// Threshold is the smallest confidence margin (see
+	// Confidence) for which the model's classification is
+	// used.
+	Threshold float64
+}
+
+// DeserializeNeutralBand deserializes a NeutralBand.
+func DeserializeNeutralBand(d []byte) (*NeutralBand, error) {
+	slice, err := serializer.DeserializeSlice(d)
+	if err != nil {
+		return nil, err
+	}
+	if len(slice) != 2 {
+		return nil, errors.New("invalid NeutralBand slice")
+	}
+	model, ok := slice[0].(ProbabilityModel)
+	threshold, ok1 := slice[1].(serializer.Float64)
+	if !ok || !ok1 {
+		return nil, errors.New("invalid NeutralBand slice")
+	}
+	return &NeutralBand{Model: model, Threshold: float64(threshold)}, nil
+}
+
+// Classify classifies the text with the wrapped model,
+// or returns Neutral if the model is not confident.
+func (n *NeutralBand) Classify(text string) Sentiment {
+	best, margin := Confidence(n.Model.Probabilities(text))
+	if margin < n.Threshold {
+		return Neutral
+	}
+	return best
+}
+
+// Probabilities returns the wrapped model's
+// probabilities, agreeing with Classify.
+// If the model is not confident, the probability of its
+// best guess is moved to Neutral, which makes Neutral the
+// most likely sentiment.
+func (n *NeutralBand) Probabilities(text string) map[Sentiment]float64 {
+	probs := n.Model.Probabilities(text)
+	best, margin := Confidence(probs)
+	if margin >= n.Threshold || best == Neutral {
+		return probs
+	}
+	res := map[Sentiment]float64{}
+	for sentiment, prob := range probs {
+		res[sentiment] = prob
+	}
+	res[Neutral] += res[best]
+	res[best] = 0
+	return res
+}
+
+// TopFeatures returns the wrapped model's top features,
+// or nil if the wrapped model is not a FeatureModel.
+func (n *NeutralBand) TopFeatures(text string, favored, other Sentiment,
+	count int) []string {
+	if featureModel, ok := n.Model.(FeatureModel); ok {
+		return featureModel.TopFeatures(text, favored, other, count)
+	}
+	return nil
+}
+
+// Train trains the wrapped model, keeping the threshold.
+func (n *NeutralBand) Train(s []*Sample) {
+	n.Model.Train(s)
+}
+
+// SerializerType gives the unique ID used to serialize
+// NeutralBands with the serializer package.
+func (n *NeutralBand) SerializerType() string {
+	return "github.com/unixpickle/sentigraph.NeutralBand"
+}
+
+// Serialize serializes the wrapped model and the
+// threshold.
+func (n *NeutralBand) Serialize() ([]byte, error) {
+	return serializer.SerializeSlice([]serializer.Serializer{
+		n.Model,
+		serializer.Float64(n.Threshold),
+	})
+}
+
+// TuneNeutralBand finds the threshold which maximizes the
+// macro-averaged F1 score on a labelled set of samples,
+// given a model's probabilities for each sample.
+// It returns the threshold and the resulting score.
+func TuneNeutralBand(probs []map[Sentiment]float64, actual []Sentiment) (float64, float64) {
+	predictions := make([]Sentiment, len(probs))
+	margins := make([]float64, len(probs))
+	confusion := Confusion{}
+	for i, p := range probs {
+		predictions[i], margins[i] = Confidence(p)
+		confusion.Add(actual[i], predictions[i])
+	}
+
+	order := make([]int, len(probs))
+	for i := range order {
+		order[i] = i
+	}
+	sort.Slice(order, func(i, j int) bool {
+		return margins[order[i]] < margins[order[j]]
+	})
+
+	// Raise the threshold past one margin at a time,
+	// turning the least confident predictions neutral.
+	var bestThreshold float64
+	bestScore := confusion.MacroF1()
+	for i, idx := range order {
+		confusion.add(actual[idx], predictions[idx], -1)
+		confusion.add(actual[idx], Neutral, 1)
+		if i+1 < len(order) && margins[order[i+1]] == margins[idx] {
+			continue
+		}
+		if score := confusion.MacroF1(); score > bestScore {
+			bestScore = score
+			if i+1 < len(order) {
+				bestThreshold = (margins[idx] + margins[order[i+1]]) / 2
+			} else {
+				bestThreshold = margins[idx] + 1e-8
+			}
+		}
+	}
+	return bestThreshold, bestScore
+}
@@ -0,0 +1,105 @@
+// Command neutral wraps a model so that it classifies
+// text as neutral when it is not confident, tuning the
+// confidence threshold on a labelled dev set.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"github.com/unixpickle/sentigraph"
+	"github.com/unixpickle/serializer"
+)
+
+const (
+	ModelArg  = 0
+	CorpusArg = 1
+	OutputArg = 2
+)
+
+func main() {
+	var threshold float64
+	flag.Float64Var(&threshold, "threshold", -1,
+		"use this threshold instead of tuning one")
+	flag.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage:", os.Args[0],
+			"[flags] model_file dev.csv output_model")
+		fmt.Fprintln(os.Stderr, "\nFlags:")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if flag.NArg() != 3 {
+		flag.Usage()
+		os.Exit(1)
+	}
+
+	model, err := sentigraph.ReadModel(flag.Arg(ModelArg))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Failed to read model:", err)
+		os.Exit(1)
+	}
+	if band, ok := model.(*sentigraph.NeutralBand); ok {
+		model = band.Model
+	}
+	probModel, ok := model.(sentigraph.ProbabilityModel)
+	if !ok {
+		fmt.Fprintf(os.Stderr, "Model does not produce probabilities: %T\n", model)
+		os.Exit(1)
+	}
+
+	samples := readSamples()
+	probs := sentigraph.BatchProbabilities(probModel, samples)
+	actual := make([]sentigraph.Sentiment, len(samples))
+	for i, sample := range samples {
+		actual[i] = sample.Sentiment
+	}
+
+	fmt.Printf("Macro-F1 without neutral band: %.4f\n", macroF1(probs, actual, 0))
+	if threshold < 0 {
+		threshold, _ = sentigraph.TuneNeutralBand(probs, actual)
+	}
+	fmt.Printf("Macro-F1 with threshold %.4f: %.4f\n", threshold,
+		macroF1(probs, actual, threshold))
+
+	band := &sentigraph.NeutralBand{Model: probModel, Threshold: threshold}
+	data, err := serializer.SerializeWithType(band)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Failed to serialize model:", err)
+		os.Exit(1)
+	}
+	if err := ioutil.WriteFile(flag.Arg(OutputArg), data, 0755); err != nil {
+		fmt.Fprintln(os.Stderr, "Failed to write model file:", err)
+		os.Exit(1)
+	}
+}
+
+func readSamples() []*sentigraph.Sample {
+	corpusFile, err := os.Open(flag.Arg(CorpusArg))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Failed to open corpus:", err)
+		os.Exit(1)
+	}
+	defer corpusFile.Close()
+	samples, err := sentigraph.ReadSamples(corpusFile)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Failed to parse corpus:", err)
+		os.Exit(1)
+	}
+	return samples
+}
+
+func macroF1(probs []map[sentigraph.Sentiment]float64, actual []sentigraph.Sentiment,
+	threshold float64) float64 {
+	confusion := sentigraph.Confusion{}
+	for i, p := range probs {
+		predicted, margin := sentigraph.Confidence(p)
+		if margin < threshold {
+			predicted = sentigraph.Neutral
+		}
+		confusion.Add(actual[i], predicted)
+	}
+	return confusion.MacroF1()
+}
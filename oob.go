@@ -0,0 +1,174 @@
+package sentigraph
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"runtime"
+	"sync"
+
+	"github.com/unixpickle/serializer"
+	"github.com/unixpickle/weakai/idtrees"
+)
+
+func init() {
+	var o OOBEstimate
+	serializer.RegisterTypedDeserializer(o.SerializerType(), DeserializeOOBEstimate)
+}
+
+// An OOBEstimate is an out-of-bag estimate of how well a
+// Forest generalizes.
+// Each training sample is classified using only the trees
+// which were trained without it, so the estimate comes
+// for free without a separate test set.
+type OOBEstimate struct {
+	// Samples is the number of training samples which
+	// were left out of at least one tree.
+	Samples int
+
+	// Correct is the number of those samples which were
+	// classified correctly.
+	Correct int
+
+	// Confusion counts the classifications of those
+	// samples.
+	Confusion Confusion
+}
+
+// DeserializeOOBEstimate deserializes an OOBEstimate.
+func DeserializeOOBEstimate(d []byte) (*OOBEstimate, error) {
+	var res OOBEstimate
+	if err := json.Unmarshal(d, &res); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+// Accuracy returns the fraction of samples which were
+// classified correctly.
+func (o *OOBEstimate) Accuracy() float64 {
+	if o.Samples == 0 {
+		return 0
+	}
+	return float64(o.Correct) / float64(o.Samples)
+}
+
+// String returns a human-readable report containing the
+// accuracy and the confusion matrix.
+func (o *OOBEstimate) String() string {
+	var classes []Sentiment
+	for _, sent := range FineSentiments {
+		if len(o.Confusion[sent]) > 0 {
+			classes = append(classes, sent)
+		}
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "out-of-bag accuracy: %d/%d (%.2f%%)\n", o.Correct,
+		o.Samples, o.Accuracy()*100)
+	fmt.Fprintf(&buf, "%-10s", "actual")
+	for _, predicted := range classes {
+		fmt.Fprintf(&buf, " %10d", predicted.Score())
+	}
+	fmt.Fprintf(&buf, " %10s\n", "recall")
+	for _, actual := range classes {
+		var total int
+		for _, c := range o.Confusion[actual] {
+			total += c
+		}
+		fmt.Fprintf(&buf, "%-10d", actual.Score())
+		for _, predicted := range classes {
+			fmt.Fprintf(&buf, " %10d", o.Confusion[actual][predicted])
+		}
+		fmt.Fprintf(&buf, " %9.2f%%\n", 100*float64(o.Confusion[actual][actual])/
+			float64(total))
+	}
+	return buf.String()
+}
+
+// SerializerType gives the unique ID used to serialize
+// OOBEstimates with the serializer package.
+func (o *OOBEstimate) SerializerType() string {
+	return "github.com/unixpickle/sentigraph.OOBEstimate"
+}
+
+// Serialize serializes the estimate.
+func (o *OOBEstimate) Serialize() ([]byte, error) {
+	return json.Marshal(o)
+}
+
+// oobTracker accumulates the votes of each tree for the
+// training samples it did not see.
+type oobTracker struct {
+	samples []idtrees.Sample
+	votes   [][]float64
+}
+
+func newOOBTracker(samples []idtrees.Sample) *oobTracker {
+	return &oobTracker{
+		samples: samples,
+		votes:   make([][]float64, len(samples)),
+	}
+}
+
+// AddTree adds the votes of a tree for every sample whose
+// index is not in inBag.
+func (o *oobTracker) AddTree(t *idtrees.Tree, inBag map[int]bool) {
+	numShards := runtime.GOMAXPROCS(0)
+	var wg sync.WaitGroup
+	for shard := 0; shard < numShards; shard++ {
+		wg.Add(1)
+		go func(shard int) {
+			defer wg.Done()
+			for i := shard; i < len(o.samples); i += numShards {
+				if inBag[i] {
+					continue
+				}
+				if o.votes[i] == nil {
+					o.votes[i] = make([]float64, len(FineSentiments))
+				}
+				for class, prob := range treeClassify(t, o.samples[i]) {
+					o.votes[i][class.(Sentiment)] += prob
+				}
+			}
+		}(shard)
+	}
+	wg.Wait()
+}
+
+// Estimate computes the out-of-bag estimate from the
+// votes so far.
+func (o *oobTracker) Estimate() *OOBEstimate {
+	res := &OOBEstimate{Confusion: Confusion{}}
+	for i, votes := range o.votes {
+		if votes == nil {
+			continue
+		}
+		var predicted Sentiment
+		for _, sent := range FineSentiments {
+			if votes[sent] > votes[predicted] {
+				predicted = sent
+			}
+		}
+		actual := o.samples[i].Class().(Sentiment)
+		res.Confusion.Add(actual, predicted)
+		res.Samples++
+		if actual == predicted {
+			res.Correct++
+		}
+	}
+	return res
+}
+
+// treeClassify classifies a sample with a single tree.
+// It returns nil if the tree has no branch for one of the
+// sample's attribute values.
+func treeClassify(t *idtrees.Tree, s idtrees.Sample) map[idtrees.Class]float64 {
+	for t != nil && t.Classification == nil {
+		t = t.ValSplit[s.Attr(t.Attr)]
+	}
+	if t == nil {
+		return nil
+	}
+	return t.Classification
+}
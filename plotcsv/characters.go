@@ -0,0 +1,77 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+	"unicode"
+)
+
+// A Character is a name to track through the text,
+// along with any aliases by which it is also known.
+type Character struct {
+	Name    string
+	Aliases []string
+}
+
+// readCharacters reads a character file.
+// Each line of the file lists a character's name followed
+// by its aliases, separated by commas, as in:
+//
+//	Elizabeth Bennet, Elizabeth, Lizzy, Miss Bennet
+func readCharacters(path string) []*Character {
+	f, err := os.Open(path)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Failed to open character file:", err)
+		os.Exit(1)
+	}
+	defer f.Close()
+
+	var res []*Character
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var names []string
+		for _, name := range strings.Split(scanner.Text(), ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				names = append(names, name)
+			}
+		}
+		if len(names) > 0 {
+			res = append(res, &Character{Name: names[0], Aliases: names})
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		fmt.Fprintln(os.Stderr, "Failed to read character file:", err)
+		os.Exit(1)
+	}
+	return res
+}
+
+// Mentioned checks if the sentence mentions the character
+// by any of its aliases.
+// Aliases are matched case-sensitively on whole words, so
+// that "Will" does not match "will" or "Willoughby".
+func (c *Character) Mentioned(sentence string) bool {
+	padded := " " + strings.Join(nameWords(sentence), " ") + " "
+	for _, alias := range c.Aliases {
+		if strings.Contains(padded, " "+strings.Join(nameWords(alias), " ")+" ") {
+			return true
+		}
+	}
+	return false
+}
+
+// nameWords splits text into words with surrounding
+// punctuation (including possessives) removed.
+func nameWords(text string) []string {
+	var res []string
+	for _, word := range strings.Fields(text) {
+		word = strings.TrimFunc(word, unicode.IsPunct)
+		word = strings.TrimSuffix(strings.TrimSuffix(word, "'s"), "’s")
+		if word != "" {
+			res = append(res, word)
+		}
+	}
+	return res
+}
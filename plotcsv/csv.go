@@ -6,25 +6,37 @@ import (
 	"io"
 	"os"
 	"sort"
+	"strconv"
 
 	"github.com/unixpickle/sentigraph"
 )
 
+// writeCSV writes one row per data point.
+// Each row contains the position and the sentiment score,
+// followed by the probability of each emotion (in the
+// order of sentigraph.AllEmotions) if emotions were
+// computed, or by the character's name if characters
+// are being tracked.
+// If the moods were smoothed, the smoothed sentiment
+// score comes last.
 func writeCSV(w io.Writer, points []*DataPoint) {
 	sort.Sort(PointSorter(points))
 
 	writer := csv.NewWriter(w)
 	for _, point := range points {
-		var sentiment string
-		switch point.Sentiment {
-		case sentigraph.Negative:
-			sentiment = "-1"
-		case sentigraph.Positive:
-			sentiment = "1"
-		case sentigraph.Neutral:
-			sentiment = "0"
-		}
+		sentiment := strconv.Itoa(point.Sentiment.Score())
 		record := []string{fmt.Sprintf("%.06f", point.Position), sentiment}
+		if point.Character != "" {
+			record = append(record, point.Character)
+		}
+		if point.Emotions != nil {
+			for _, emotion := range sentigraph.AllEmotions {
+				record = append(record, fmt.Sprintf("%.04f", point.Emotions[emotion]))
+			}
+		}
+		if point.Smoothed != nil {
+			record = append(record, strconv.Itoa(point.Smoothed.Score()))
+		}
 		if err := writer.Write(record); err != nil {
 			fmt.Fprintln(os.Stderr, "Failed to write output:", err)
 			os.Exit(1)
@@ -3,6 +3,7 @@
 package main
 
 import (
+	"flag"
 	"fmt"
 	"io/ioutil"
 	"os"
@@ -14,9 +15,9 @@ import (
 )
 
 const (
-	ModelArg  = 1
-	TextArg   = 2
-	OutputArg = 3
+	ModelArg  = 0
+	TextArg   = 1
+	OutputArg = 2
 )
 
 type SentenceInfo struct {
@@ -27,17 +28,99 @@ type SentenceInfo struct {
 type DataPoint struct {
 	Sentiment sentigraph.Sentiment
 	Position  float64
+
+	// Emotions is nil unless an emotion model is used.
+	Emotions map[sentigraph.Emotion]float64
+
+	// Character is the name of the character mentioned
+	// in the sentence, if characters are being tracked.
+	Character string
+
+	// Probabilities and Smoothed are only set when the
+	// moods are being smoothed.
+	// Probabilities stores the model's output for the
+	// sentence, and Smoothed stores the mood chosen by
+	// the hidden Markov model.
+	Probabilities map[sentigraph.Sentiment]float64
+	Smoothed      *sentigraph.Sentiment
 }
 
 func main() {
-	if len(os.Args) != 4 {
+	var emotionsPath string
+	var charactersPath string
+	var smoothing string
+	var persistence float64
+	var windowSize int
+	var windowUnit string
+	var stride int
+	flag.StringVar(&emotionsPath, "emotions", "",
+		"emotion model for adding one column per emotion")
+	flag.StringVar(&charactersPath, "characters", "",
+		"file of character names and aliases (one character per line) to track")
+	flag.StringVar(&smoothing, "smooth", "",
+		"smooth the moods with a hidden Markov model (viterbi or posterior)")
+	flag.Float64Var(&persistence, "persistence", 0,
+		"probability that the mood persists between sentences (0 to learn it)")
+	flag.IntVar(&windowSize, "window", 0,
+		"classify windows of this many words or sentences around each position")
+	flag.StringVar(&windowUnit, "window-unit", "sentences",
+		"unit of the window size and stride (words or sentences)")
+	flag.IntVar(&stride, "stride", 1,
+		"distance between the centres of consecutive windows")
+	flag.Usage = func() {
 		fmt.Fprintln(os.Stderr, "Usage:", os.Args[0],
-			"model_file text_file ouput.csv")
+			"[flags] model_file text_file ouput.csv")
+		fmt.Fprintln(os.Stderr, "\nFlags:")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if flag.NArg() != 3 {
+		flag.Usage()
+		os.Exit(1)
+	}
+
+	var characters []*Character
+	if charactersPath != "" {
+		if emotionsPath != "" {
+			fmt.Fprintln(os.Stderr, "Cannot track both emotions and characters.")
+			os.Exit(1)
+		}
+		characters = readCharacters(charactersPath)
+	}
+	if smoothing != "" {
+		if smoothing != "viterbi" && smoothing != "posterior" {
+			fmt.Fprintln(os.Stderr, "Unknown smoothing method:", smoothing)
+			os.Exit(1)
+		} else if characters != nil {
+			fmt.Fprintln(os.Stderr, "Cannot smooth moods while tracking characters.")
+			os.Exit(1)
+		}
+	}
+
+	if persistence < 0 || persistence >= 1 {
+		fmt.Fprintln(os.Stderr, "The persistence must be in [0, 1).")
 		os.Exit(1)
 	}
 
-	sentences := readSentences()
-	dataPoints := classifySentences(sentences)
+	if windowSize < 0 || stride < 1 {
+		fmt.Fprintln(os.Stderr, "The window size and stride must be positive.")
+		os.Exit(1)
+	}
+
+	var sentences <-chan *SentenceInfo
+	if windowSize == 0 {
+		sentences = readSentences()
+	} else if windowUnit == "sentences" {
+		sentences = sentenceWindows(readSentences(), windowSize, stride)
+	} else if windowUnit == "words" {
+		sentences = wordWindows(windowSize, stride)
+	} else {
+		fmt.Fprintln(os.Stderr, "Unknown window unit:", windowUnit)
+		os.Exit(1)
+	}
+	dataPoints := classifySentences(sentences, emotionsPath, characters,
+		smoothing != "")
 
 	var points []*DataPoint
 	for point := range dataPoints {
@@ -48,7 +131,11 @@ func main() {
 
 	fmt.Println()
 
-	outFile, err := os.Create(os.Args[OutputArg])
+	if smoothing != "" {
+		smoothPoints(points, smoothing, persistence)
+	}
+
+	outFile, err := os.Create(flag.Arg(OutputArg))
 	if err != nil {
 		fmt.Fprintln(os.Stderr, "Failed to create output:", err)
 		os.Exit(1)
@@ -59,17 +146,11 @@ func main() {
 }
 
 func readSentences() <-chan *SentenceInfo {
-	text, err := ioutil.ReadFile(os.Args[TextArg])
-	if err != nil {
-		fmt.Fprintln(os.Stderr, "Failed to read text file:", err)
-		os.Exit(1)
-	}
-
+	fields := readWords()
 	res := make(chan *SentenceInfo)
 
 	go func() {
 		var curSentence []string
-		fields := strings.Fields(string(text))
 		for i, word := range fields {
 			curSentence = append(curSentence, word)
 			if sentenceEnded(word) {
@@ -87,12 +168,43 @@ func readSentences() <-chan *SentenceInfo {
 	return res
 }
 
-func classifySentences(sentences <-chan *SentenceInfo) <-chan *DataPoint {
-	model, err := sentigraph.ReadModel(os.Args[ModelArg])
+func readWords() []string {
+	text, err := ioutil.ReadFile(flag.Arg(TextArg))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Failed to read text file:", err)
+		os.Exit(1)
+	}
+	return strings.Fields(string(text))
+}
+
+// classifySentences classifies the sentences in parallel.
+//
+// If characters is non-nil, only sentences which mention
+// a character are classified, and a data point is
+// produced for each character mentioned.
+//
+// If probabilities is true, each data point includes
+// the model's probabilities.
+func classifySentences(sentences <-chan *SentenceInfo, emotionsPath string,
+	characters []*Character, probabilities bool) <-chan *DataPoint {
+	model, err := sentigraph.ReadModel(flag.Arg(ModelArg))
 	if err != nil {
 		fmt.Fprintln(os.Stderr, "Failed to read model:", err)
 		os.Exit(1)
 	}
+	probModel, ok := model.(sentigraph.ProbabilityModel)
+	if probabilities && !ok {
+		fmt.Fprintf(os.Stderr, "Model does not produce probabilities: %T\n", model)
+		os.Exit(1)
+	}
+	var emotionModel sentigraph.EmotionModel
+	if emotionsPath != "" {
+		emotionModel, err = sentigraph.ReadEmotionModel(emotionsPath)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "Failed to read emotion model:", err)
+			os.Exit(1)
+		}
+	}
 	resChan := make(chan *DataPoint)
 	var wg sync.WaitGroup
 	for i := 0; i < runtime.GOMAXPROCS(0); i++ {
@@ -100,11 +212,21 @@ func classifySentences(sentences <-chan *SentenceInfo) <-chan *DataPoint {
 		go func() {
 			defer wg.Done()
 			for sentence := range sentences {
-				sent := model.Classify(sentence.Text)
-				resChan <- &DataPoint{
-					Sentiment: sent,
+				if characters != nil {
+					classifyCharacters(model, sentence, characters, resChan)
+					continue
+				}
+				point := &DataPoint{
+					Sentiment: model.Classify(sentence.Text),
 					Position:  sentence.Position,
 				}
+				if probabilities {
+					point.Probabilities = probModel.Probabilities(sentence.Text)
+				}
+				if emotionModel != nil {
+					point.Emotions = emotionModel.Emotions(sentence.Text)
+				}
+				resChan <- point
 			}
 		}()
 	}
@@ -115,6 +237,26 @@ func classifySentences(sentences <-chan *SentenceInfo) <-chan *DataPoint {
 	return resChan
 }
 
+func classifyCharacters(model sentigraph.Model, sentence *SentenceInfo,
+	characters []*Character, resChan chan<- *DataPoint) {
+	var sent sentigraph.Sentiment
+	var classified bool
+	for _, character := range characters {
+		if !character.Mentioned(sentence.Text) {
+			continue
+		}
+		if !classified {
+			sent = model.Classify(sentence.Text)
+			classified = true
+		}
+		resChan <- &DataPoint{
+			Sentiment: sent,
+			Position:  sentence.Position,
+			Character: character.Name,
+		}
+	}
+}
+
 func sentenceEnded(s string) bool {
 	if s == "Dr." || s == "Mr." || s == "Mrs." || s == "Ms." {
 		return false
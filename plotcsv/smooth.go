@@ -0,0 +1,60 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"sort"
+
+	"github.com/unixpickle/sentigraph"
+)
+
+// SmoothingIterations is the number of Baum-Welch
+// iterations used to learn the mood persistence.
+const SmoothingIterations = 20
+
+// DefaultPersistence is the initial persistence when it
+// is being learned.
+const DefaultPersistence = 0.9
+
+// smoothPoints sets the smoothed mood of each point,
+// using either the most likely sequence of moods
+// ("viterbi") or the most likely mood for each sentence
+// on its own ("posterior").
+func smoothPoints(points []*DataPoint, method string, persistence float64) {
+	sort.Sort(PointSorter(points))
+	emissions := make([]map[sentigraph.Sentiment]float64, len(points))
+	for i, point := range points {
+		emissions[i] = point.Probabilities
+	}
+
+	learn := persistence == 0
+	if learn {
+		persistence = DefaultPersistence
+	}
+	hmm, err := sentigraph.NewMoodHMM(emissions, persistence)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Failed to smooth moods:", err)
+		os.Exit(1)
+	}
+	if learn {
+		hmm.Fit(emissions, SmoothingIterations)
+		log.Printf("Learned mood persistence: %.04f", hmm.Persistence)
+	}
+
+	// The persistence was validated above, so these
+	// cannot fail.
+	var moods []sentigraph.Sentiment
+	if method == "viterbi" {
+		moods, _ = hmm.Viterbi(emissions)
+	} else {
+		posteriors, _ := hmm.Posteriors(emissions)
+		for _, posterior := range posteriors {
+			mood, _ := sentigraph.Confidence(posterior)
+			moods = append(moods, mood)
+		}
+	}
+	for i, point := range points {
+		point.Smoothed = &moods[i]
+	}
+}
@@ -0,0 +1,67 @@
+package main
+
+import "strings"
+
+// sentenceWindows combines each group of size consecutive
+// sentences into one piece of text, positioned at the
+// sentence in the middle of the group.
+// The middle sentences of consecutive windows are stride
+// sentences apart.
+// Near the start and end of the text, the windows are
+// truncated.
+func sentenceWindows(sentences <-chan *SentenceInfo, size, stride int) <-chan *SentenceInfo {
+	res := make(chan *SentenceInfo)
+	go func() {
+		var all []*SentenceInfo
+		for sentence := range sentences {
+			all = append(all, sentence)
+		}
+		for center := 0; center < len(all); center += stride {
+			start, end := windowBounds(center, size, len(all))
+			var texts []string
+			for _, sentence := range all[start:end] {
+				texts = append(texts, sentence.Text)
+			}
+			res <- &SentenceInfo{
+				Text:     strings.Join(texts, " "),
+				Position: all[center].Position,
+			}
+		}
+		close(res)
+	}()
+	return res
+}
+
+// wordWindows is like sentenceWindows, but the windows
+// contain size words and are centred on every stride-th
+// word, ignoring sentence boundaries.
+func wordWindows(size, stride int) <-chan *SentenceInfo {
+	words := readWords()
+	res := make(chan *SentenceInfo)
+	go func() {
+		for center := 0; center < len(words); center += stride {
+			start, end := windowBounds(center, size, len(words))
+			res <- &SentenceInfo{
+				Text:     strings.Join(words[start:end], " "),
+				Position: float64(center) / float64(len(words)),
+			}
+		}
+		close(res)
+	}()
+	return res
+}
+
+// windowBounds computes the range of a window of the
+// given size centred on an index, clipped to the bounds
+// of a sequence.
+func windowBounds(center, size, length int) (int, int) {
+	start := center - size/2
+	end := start + size
+	if start < 0 {
+		start = 0
+	}
+	if end > length {
+		end = length
+	}
+	return start, end
+}
@@ -5,6 +5,8 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"strconv"
+	"strings"
 )
 
 // A Sentiment indicates the positivity/negativity of a
@@ -13,16 +15,120 @@ type Sentiment int
 
 var AllSentiments = []Sentiment{Neutral, Negative, Positive}
 
+// FineSentiments contains every Sentiment on the 5-point
+// scale.
+// It begins with the elements of AllSentiments so that
+// code which iterates over it breaks ties the same way
+// for 3-class models.
+var FineSentiments = []Sentiment{Neutral, Negative, Positive, VeryNegative,
+	VeryPositive}
+
 const (
 	Neutral Sentiment = iota
 	Negative
 	Positive
+
+	// VeryNegative and VeryPositive extend the scale to
+	// five points. They come after the original values
+	// so that existing 3-class models remain valid.
+	VeryNegative
+	VeryPositive
 )
 
+// Score returns the position of the sentiment on an
+// ordinal scale from -2 (very negative) to 2 (very
+// positive).
+func (s Sentiment) Score() int {
+	switch s {
+	case VeryNegative:
+		return -2
+	case Negative:
+		return -1
+	case Positive:
+		return 1
+	case VeryPositive:
+		return 2
+	default:
+		return 0
+	}
+}
+
+// Coarse maps the sentiment onto the 3-class scale of
+// AllSentiments.
+func (s Sentiment) Coarse() Sentiment {
+	switch s {
+	case VeryNegative:
+		return Negative
+	case VeryPositive:
+		return Positive
+	default:
+		return s
+	}
+}
+
+// SentimentForScore is the inverse of Sentiment.Score.
+// The second return value is false if the score is out
+// of range.
+func SentimentForScore(score int) (Sentiment, bool) {
+	switch score {
+	case -2:
+		return VeryNegative, true
+	case -1:
+		return Negative, true
+	case 0:
+		return Neutral, true
+	case 1:
+		return Positive, true
+	case 2:
+		return VeryPositive, true
+	default:
+		return 0, false
+	}
+}
+
+// CoarseSamples returns copies of the samples with their
+// sentiments mapped onto the 3-class scale.
+func CoarseSamples(s []*Sample) []*Sample {
+	res := make([]*Sample, len(s))
+	for i, sample := range s {
+		newSample := *sample
+		newSample.Sentiment = sample.Sentiment.Coarse()
+		res[i] = &newSample
+	}
+	return res
+}
+
 // Sample is a single textual training or testing sample.
 type Sample struct {
 	Contents  string
 	Sentiment Sentiment
+
+	// Weight is the relative importance of the sample
+	// during training.
+	// A Weight of 0 is treated as a weight of 1, so that
+	// unweighted corpora need not set it.
+	// It must not be negative.
+	Weight float64
+}
+
+// checkWeights returns an error if any sample has a
+// negative weight.
+func checkWeights(s []*Sample) error {
+	for i, sample := range s {
+		if sample.Weight < 0 {
+			return fmt.Errorf("sample %d has negative weight %f", i, sample.Weight)
+		}
+	}
+	return nil
+}
+
+// TrainingWeight returns the effective weight of the
+// sample, taking the default weight into account.
+func (s *Sample) TrainingWeight() float64 {
+	if s.Weight == 0 {
+		return 1
+	}
+	return s.Weight
 }
 
 // ReadSamples reads samples from a CSV stream.
@@ -34,6 +140,9 @@ type Sample struct {
 //  - Format: "0"/"2"/"4",ignored,ignored,ignored,ignored,tweet_body
 // - Corpus: http://www.sananalytics.com/lab/twitter-sentiment/
 //  - Format: ignored,"positive"/"negative"/"neutral",ignored,ignored,tweet_body
+// - Corpus: star-rated reviews with a header row
+//  - Format: "rating"/"stars",ignored...,review_body
+//  - Ratings 1 through 5 map to VeryNegative through VeryPositive.
 //
 // Other corpora may be supported in the future.
 func ReadSamples(r io.Reader) ([]*Sample, error) {
@@ -51,6 +160,8 @@ func ReadSamples(r io.Reader) ([]*Sample, error) {
 		return read024Samples(source, first)
 	} else if len(first) == 5 && first[1] == "Sentiment" {
 		return readPosNegNeutIrrelSamples(source)
+	} else if header := strings.ToLower(first[0]); header == "rating" || header == "stars" {
+		return readStarSamples(source)
 	}
 	return nil, errors.New("unknown data format")
 }
@@ -107,3 +218,27 @@ RecordLoop:
 
 	return samples, nil
 }
+
+func readStarSamples(r *csv.Reader) ([]*Sample, error) {
+	r.FieldsPerRecord = -1
+	records, err := r.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	samples := make([]*Sample, len(records))
+	for i, record := range records {
+		stars, err := strconv.ParseFloat(strings.TrimSpace(record[0]), 64)
+		if err != nil {
+			return nil, fmt.Errorf("record %d: invalid rating %s", i, record[0])
+		}
+		sentiment, ok := SentimentForScore(int(stars+0.5) - 3)
+		if !ok {
+			return nil, fmt.Errorf("record %d: invalid rating %s", i, record[0])
+		}
+		samples[i] = &Sample{
+			Contents:  record[len(record)-1],
+			Sentiment: sentiment,
+		}
+	}
+	return samples, nil
+}
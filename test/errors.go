@@ -0,0 +1,81 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/unixpickle/sentigraph"
+)
+
+// writeErrors writes a CSV file listing the misclassified
+// samples, from the most to the least confident mistake.
+// Confident mistakes often point to mislabelled samples.
+//
+// Each row contains the text, the actual and predicted
+// sentiment scores, the confidence margin of the
+// prediction (if the model produces probabilities which
+// agree with it), and the features which most favored
+// the prediction (if the model can find them).
+func writeErrors(results []*Result, path string) {
+	var mistakes []*Result
+	for _, result := range results {
+		if result.Predicted != result.Sample.Sentiment {
+			mistakes = append(mistakes, result)
+		}
+	}
+	sort.SliceStable(mistakes, func(i, j int) bool {
+		conf1, ok1 := mistakes[i].confidence()
+		conf2, ok2 := mistakes[j].confidence()
+		if ok1 != ok2 {
+			return ok1
+		}
+		return conf1 > conf2
+	})
+
+	f, err := os.Create(path)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Failed to create errors file:", err)
+		os.Exit(1)
+	}
+	defer f.Close()
+
+	w := csv.NewWriter(f)
+	w.Write([]string{"text", "actual", "predicted", "confidence", "features"})
+	for _, result := range mistakes {
+		var confidence string
+		if conf, ok := result.confidence(); ok {
+			confidence = fmt.Sprintf("%.4f", conf)
+		}
+		w.Write([]string{
+			result.Sample.Contents,
+			strconv.Itoa(result.Sample.Sentiment.Score()),
+			strconv.Itoa(result.Predicted.Score()),
+			confidence,
+			strings.Join(result.Features, "; "),
+		})
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		fmt.Fprintln(os.Stderr, "Failed to write errors file:", err)
+		os.Exit(1)
+	}
+}
+
+// confidence returns the margin by which the prediction
+// beat the runner-up (see sentigraph.Confidence).
+// It returns false if there are no probabilities, or if
+// they favor a different sentiment than the prediction.
+func (r *Result) confidence() (float64, bool) {
+	if r.Probabilities == nil {
+		return 0, false
+	}
+	best, margin := sentigraph.Confidence(r.Probabilities)
+	if best != r.Predicted {
+		return 0, false
+	}
+	return margin, true
+}
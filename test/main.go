@@ -2,7 +2,10 @@
 package main
 
 import (
+	"encoding/json"
+	"flag"
 	"fmt"
+	"io/ioutil"
 	"os"
 	"runtime"
 	"sync"
@@ -11,59 +14,186 @@ import (
 )
 
 const (
-	ModelArg  = 1
-	CorpusArg = 2
+	ModelArg  = 0
+	CorpusArg = 1
 )
 
+// ErrorFeatureCount is the number of top features listed
+// for each misclassified sample.
+const ErrorFeatureCount = 5
+
+// A Result is the outcome of classifying one sample.
+type Result struct {
+	Sample    *sentigraph.Sample
+	Predicted sentigraph.Sentiment
+
+	// Probabilities is nil unless they are needed for
+	// the report.
+	Probabilities map[sentigraph.Sentiment]float64
+
+	// Features lists the features (for KNN models, the
+	// neighbors) which most favored the predicted
+	// sentiment over the actual one, if the sample was
+	// misclassified and errors are being dumped.
+	Features []string
+}
+
 func main() {
-	if len(os.Args) != 3 {
-		fmt.Fprintln(os.Stderr, "Usage:", os.Args[0], "model_file corpus.csv")
+	var reliabilityBins int
+	var jsonPath string
+	var errorsPath string
+	flag.IntVar(&reliabilityBins, "reliability", 0,
+		"print a reliability diagram with this many bins")
+	flag.StringVar(&jsonPath, "json", "",
+		"also write the evaluation report to this file as JSON")
+	flag.StringVar(&errorsPath, "errors", "",
+		"write the misclassified samples to this CSV file")
+	flag.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage:", os.Args[0], "[flags] model_file corpus.csv")
+		fmt.Fprintln(os.Stderr, "\nFlags:")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if flag.NArg() != 2 {
+		flag.Usage()
 		os.Exit(1)
 	}
 	model := readModel()
+	var probModel sentigraph.ProbabilityModel
+	var featureModel sentigraph.FeatureModel
+	if errorsPath != "" {
+		probModel, _ = model.(sentigraph.ProbabilityModel)
+		featureModel, _ = model.(sentigraph.FeatureModel)
+	}
+	if reliabilityBins > 0 {
+		var ok bool
+		probModel, ok = model.(sentigraph.ProbabilityModel)
+		if !ok {
+			fmt.Fprintf(os.Stderr, "Model does not produce probabilities: %T\n", model)
+			os.Exit(1)
+		}
+	}
 	sampleChan := readSamples()
-	statusChan := make(chan bool)
+	resultChan := make(chan *Result)
 
 	var wg sync.WaitGroup
 	for i := 0; i < runtime.GOMAXPROCS(0); i++ {
 		wg.Add(1)
 		go func() {
-			runSamples(model, sampleChan, statusChan)
+			runSamples(model, probModel, featureModel, sampleChan, resultChan)
 			wg.Done()
 		}()
 	}
 
 	go func() {
 		wg.Wait()
-		close(statusChan)
+		close(resultChan)
 	}()
 
-	printStatuses(statusChan)
+	results := printStatuses(resultChan)
+
+	confusion := sentigraph.Confusion{}
+	for _, result := range results {
+		confusion.Add(result.Sample.Sentiment, result.Predicted)
+	}
+	evaluation := sentigraph.Evaluate(confusion)
+	fmt.Println()
+	fmt.Print(evaluation)
+	if jsonPath != "" {
+		writeJSON(evaluation, jsonPath)
+	}
+
+	if reliabilityBins > 0 {
+		printReliability(results, reliabilityBins)
+	}
+	if errorsPath != "" {
+		writeErrors(results, errorsPath)
+	}
 }
 
-func runSamples(model sentigraph.Model, samples <-chan *sentigraph.Sample, statuses chan<- bool) {
+// runSamples classifies the samples.
+// If probModel is non-nil, it is used to compute the
+// probabilities for each sample.
+// If featureModel is non-nil, it is used to find the top
+// features of each misclassified sample.
+func runSamples(model sentigraph.Model, probModel sentigraph.ProbabilityModel,
+	featureModel sentigraph.FeatureModel, samples <-chan *sentigraph.Sample,
+	results chan<- *Result) {
 	for sample := range samples {
-		output := model.Classify(sample.Contents)
-		statuses <- output == sample.Sentiment
+		result := &Result{
+			Sample:    sample,
+			Predicted: model.Classify(sample.Contents),
+		}
+		if probModel != nil {
+			result.Probabilities = probModel.Probabilities(sample.Contents)
+		}
+		if featureModel != nil && result.Predicted != sample.Sentiment {
+			result.Features = featureModel.TopFeatures(sample.Contents,
+				result.Predicted, sample.Sentiment, ErrorFeatureCount)
+		}
+		results <- result
 	}
 }
 
-func printStatuses(statusChan <-chan bool) {
-	var total int
+func printStatuses(resultChan <-chan *Result) []*Result {
+	var results []*Result
 	var correct int
-	for status := range statusChan {
-		total++
-		if status {
+	for result := range resultChan {
+		results = append(results, result)
+		if result.Predicted == result.Sample.Sentiment {
 			correct++
 		}
-		fmt.Printf("\rGot %d/%d (%.2f%%)     ", correct, total,
-			float64(correct)/float64(total)*100)
+		fmt.Printf("\rGot %d/%d (%.2f%%)     ", correct, len(results),
+			float64(correct)/float64(len(results))*100)
 	}
 	fmt.Println("")
+	return results
+}
+
+// printReliability prints a reliability diagram, which
+// compares the model's confidence in its predictions to
+// their accuracy.
+func printReliability(results []*Result, numBins int) {
+	var probs []map[sentigraph.Sentiment]float64
+	var actual []sentigraph.Sentiment
+	for _, result := range results {
+		probs = append(probs, result.Probabilities)
+		actual = append(actual, result.Sample.Sentiment)
+	}
+	bins := sentigraph.Reliability(probs, actual, numBins)
+
+	fmt.Println("\nReliability diagram (# = accuracy, | = confidence):")
+	const barWidth = 40
+	for _, bin := range bins {
+		if bin.Count == 0 {
+			continue
+		}
+		bar := []byte(fmt.Sprintf("%-*s", barWidth+1, ""))
+		for i := 0; i < int(bin.Accuracy*barWidth+0.5); i++ {
+			bar[i] = '#'
+		}
+		bar[int(bin.Confidence*barWidth+0.5)] = '|'
+		fmt.Printf("%.2f-%.2f %7d  conf %.3f  acc %.3f  %s\n", bin.Min, bin.Max,
+			bin.Count, bin.Confidence, bin.Accuracy, bar)
+	}
+	fmt.Printf("Expected calibration error: %.4f\n", sentigraph.CalibrationError(bins))
+}
+
+func writeJSON(evaluation *sentigraph.Evaluation, path string) {
+	data, err := json.MarshalIndent(evaluation, "", "  ")
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Failed to encode report:", err)
+		os.Exit(1)
+	}
+	if err := ioutil.WriteFile(path, data, 0644); err != nil {
+		fmt.Fprintln(os.Stderr, "Failed to write report:", err)
+		os.Exit(1)
+	}
 }
 
 func readModel() sentigraph.Model {
-	model, err := sentigraph.ReadModel(os.Args[ModelArg])
+	model, err := sentigraph.ReadModel(flag.Arg(ModelArg))
 	if err != nil {
 		fmt.Fprintln(os.Stderr, "Failed to read model:", err)
 		os.Exit(1)
@@ -72,7 +202,7 @@ func readModel() sentigraph.Model {
 }
 
 func readSamples() <-chan *sentigraph.Sample {
-	corpusFile, err := os.Open(os.Args[CorpusArg])
+	corpusFile, err := os.Open(flag.Arg(CorpusArg))
 	if err != nil {
 		fmt.Fprintln(os.Stderr, "Failed to open corpus:", err)
 		os.Exit(1)
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/unixpickle/sentigraph"
+	"github.com/unixpickle/serializer"
+)
+
+// trainEmotions trains an emotion model rather than a
+// sentiment model.
+//
+// If lexiconPath is empty, the data file is read with
+// sentigraph.ReadEmotionSamples.
+// Otherwise, the data file contains one document per
+// line, and each document is labelled using the lexicon.
+func trainEmotions(lexiconPath string) {
+	var model sentigraph.EmotionModel
+
+	modelData, err := ioutil.ReadFile(flag.Arg(ModelPathArg))
+	if err == nil {
+		modelObj, err := serializer.DeserializeWithType(modelData)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "Failed to deserialize existing model:", err)
+			os.Exit(1)
+		}
+		var ok bool
+		model, ok = modelObj.(sentigraph.EmotionModel)
+		if !ok {
+			fmt.Fprintf(os.Stderr, "Invalid emotion model type: %T\n", modelObj)
+			os.Exit(1)
+		}
+		log.Println("Loaded existing model from file.")
+	} else {
+		model = sentigraph.EmotionModels[flag.Arg(ModelArg)]()
+	}
+
+	var samples []*sentigraph.EmotionSample
+	if lexiconPath != "" {
+		samples = readLexiconSamples(lexiconPath)
+	} else {
+		dataFile, err := os.Open(flag.Arg(DataPathArg))
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "Failed to open data:", err)
+			os.Exit(1)
+		}
+		defer dataFile.Close()
+		samples, err = sentigraph.ReadEmotionSamples(dataFile)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "Failed to parse data:", err)
+			os.Exit(1)
+		}
+	}
+
+	model.TrainEmotions(samples)
+
+	saveModel(model, flag.Arg(ModelPathArg))
+}
+
+func readLexiconSamples(lexiconPath string) []*sentigraph.EmotionSample {
+	lexiconFile, err := os.Open(lexiconPath)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Failed to open lexicon:", err)
+		os.Exit(1)
+	}
+	defer lexiconFile.Close()
+	lexicon, err := sentigraph.ReadEmotionLexicon(lexiconFile)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Failed to parse lexicon:", err)
+		os.Exit(1)
+	}
+
+	dataFile, err := os.Open(flag.Arg(DataPathArg))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Failed to open data:", err)
+		os.Exit(1)
+	}
+	defer dataFile.Close()
+
+	var texts []string
+	scanner := bufio.NewScanner(dataFile)
+	for scanner.Scan() {
+		if line := strings.TrimSpace(scanner.Text()); line != "" {
+			texts = append(texts, line)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		fmt.Fprintln(os.Stderr, "Failed to read data:", err)
+		os.Exit(1)
+	}
+
+	log.Println("Labelling", len(texts), "documents with lexicon...")
+	return lexicon.LabelSamples(texts)
+}
@@ -2,10 +2,15 @@
 package main
 
 import (
+	"context"
+	"flag"
 	"fmt"
 	"io/ioutil"
 	"log"
+	"math/rand"
 	"os"
+	"os/signal"
+	"path/filepath"
 	"sort"
 
 	"github.com/unixpickle/sentigraph"
@@ -13,25 +18,62 @@ import (
 )
 
 const (
-	ModelArg     = 1
-	ModelPathArg = 2
-	DataPathArg  = 3
+	ModelArg     = 0
+	ModelPathArg = 1
+	DataPathArg  = 2
 )
 
 func main() {
-	if len(os.Args) != 4 {
-		fmt.Fprintln(os.Stderr, "Usage:", os.Args[0], "model_name model_path data.csv")
-		fmt.Fprintln(os.Stderr, "\nAvailable models:")
-		for _, model := range modelNames() {
-			fmt.Fprintln(os.Stderr, " -", model)
-		}
-		fmt.Fprintln(os.Stderr)
+	var balanceName string
+	var coarse bool
+	var lexiconPath string
+	var continueTraining bool
+	var seed int64
+	var vectorsPath string
+	var vectorWords int
+	var tfidf bool
+	var keepCounts bool
+	flag.StringVar(&balanceName, "balance", "none",
+		"class balancing (none, undersample, oversample, or reweight)")
+	flag.BoolVar(&coarse, "coarse", false,
+		"map 5-point sentiments onto positive/negative/neutral")
+	flag.BoolVar(&continueTraining, "continue", false,
+		"add the data to an existing model instead of retraining it from scratch")
+	flag.Int64Var(&seed, "seed", 0,
+		"random seed for training (0 picks one and saves it with the model)")
+	flag.StringVar(&lexiconPath, "lexicon", "",
+		"label a plain text file (one document per line) with an emotion lexicon")
+	flag.StringVar(&vectorsPath, "vectors", "",
+		"pretrained word vectors in GloVe or word2vec text format")
+	flag.IntVar(&vectorWords, "vector-words", 100000,
+		"maximum number of word vectors to load (0 for all)")
+	flag.BoolVar(&tfidf, "tfidf", false,
+		"weight word vectors by TF-IDF instead of averaging them")
+	flag.BoolVar(&keepCounts, "keep-counts", false,
+		"save a bayes model's raw counts, so that it can be continued or merged")
+	flag.Usage = printUsage
+	flag.Parse()
+
+	if flag.NArg() != 3 {
+		printUsage()
+		os.Exit(1)
+	}
+
+	if _, ok := sentigraph.EmotionModels[flag.Arg(ModelArg)]; ok {
+		trainEmotions(lexiconPath)
+		return
+	}
+
+	balance, err := sentigraph.ParseBalanceMode(balanceName)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
 		os.Exit(1)
 	}
 
 	var model sentigraph.Model
+	var loaded bool
 
-	modelData, err := ioutil.ReadFile(os.Args[ModelPathArg])
+	modelData, err := ioutil.ReadFile(flag.Arg(ModelPathArg))
 	if err == nil {
 		modelObj, err := serializer.DeserializeWithType(modelData)
 		if err != nil {
@@ -45,16 +87,41 @@ func main() {
 			os.Exit(1)
 		}
 		log.Println("Loaded existing model from file.")
+		loaded = true
 	} else {
-		constructor, ok := sentigraph.Models[os.Args[ModelArg]]
+		constructor, ok := sentigraph.Models[flag.Arg(ModelArg)]
 		if !ok {
-			fmt.Fprintln(os.Stderr, "Unknown model:", os.Args[1])
+			fmt.Fprintln(os.Stderr, "Unknown model:", flag.Arg(ModelArg))
 			os.Exit(1)
 		}
 		model = constructor()
 	}
 
-	dataFile, err := os.Open(os.Args[DataPathArg])
+	if vectorsPath != "" {
+		if continueTraining && loaded {
+			fmt.Fprintln(os.Stderr, "Cannot change the word vectors of an existing model.")
+			os.Exit(1)
+		}
+		vecModel, ok := model.(sentigraph.VectorModel)
+		if !ok {
+			fmt.Fprintf(os.Stderr, "Model cannot use word vectors: %T\n", model)
+			os.Exit(1)
+		}
+		vectors := readWordVectors(vectorsPath, vectorWords)
+		vectors.TFIDF = tfidf
+		vecModel.SetWordVectors(vectors)
+	}
+
+	if keepCounts {
+		bayes, ok := model.(*sentigraph.Bayes)
+		if !ok {
+			fmt.Fprintf(os.Stderr, "Model has no counts to keep: %T\n", model)
+			os.Exit(1)
+		}
+		bayes.KeepCounts = true
+	}
+
+	dataFile, err := os.Open(flag.Arg(DataPathArg))
 	if err != nil {
 		fmt.Fprintln(os.Stderr, "Failed to open data:", err)
 		os.Exit(1)
@@ -66,24 +133,146 @@ func main() {
 		os.Exit(1)
 	}
 
-	model.Train(samples)
+	if coarse {
+		samples = sentigraph.CoarseSamples(samples)
+	}
+	if seed == 0 {
+		seed = sentigraph.NewSeed()
+	}
+	if randModel, ok := model.(sentigraph.RandomModel); ok {
+		randModel.SetSeed(seed)
+	}
+
+	if balance != sentigraph.NoBalance {
+		r := rand.New(rand.NewSource(seed))
+		samples, err = sentigraph.BalanceSamples(samples, balance, r)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "Failed to balance samples:", err)
+			os.Exit(1)
+		}
+		log.Println("Balanced classes to", len(samples), "samples.")
+	}
+
+	if continueTraining && loaded {
+		// Updates are not reported or cancellable, so an
+		// interrupt simply exits without saving.
+		incModel, ok := model.(sentigraph.IncrementalModel)
+		if !ok {
+			fmt.Fprintf(os.Stderr, "Model cannot be trained incrementally: %T\n", model)
+			os.Exit(1)
+		}
+		if err := incModel.Update(samples); err != nil {
+			fmt.Fprintln(os.Stderr, "Failed to update model:", err)
+			os.Exit(1)
+		}
+	} else {
+		trainModel(model, samples)
+	}
+
+	saveModel(model, flag.Arg(ModelPathArg))
+}
+
+// trainModel trains the model, showing a progress bar if
+// the model supports it.
+//
+// If the user interrupts training, the partially trained
+// model is saved as a checkpoint and the program exits.
+// A second interrupt exits immediately.
+func trainModel(model sentigraph.Model, samples []*sentigraph.Sample) {
+	ctxModel, ok := model.(sentigraph.ContextModel)
+	if !ok {
+		model.Train(samples)
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	interrupts := make(chan os.Signal, 1)
+	signal.Notify(interrupts, os.Interrupt)
+	go func() {
+		if _, ok := <-interrupts; ok {
+			signal.Stop(interrupts)
+			cancel()
+		}
+	}()
+	defer signal.Stop(interrupts)
+
+	var bar progressBar
+	var madeProgress bool
+	err := ctxModel.TrainContext(ctx, samples, func(p sentigraph.TrainProgress) {
+		if p.Done > 0 {
+			madeProgress = true
+		}
+		bar.Update(p)
+	})
+	bar.Finish()
+	if err == nil {
+		return
+	} else if ctx.Err() == nil {
+		fmt.Fprintln(os.Stderr, "Training failed:", err)
+		os.Exit(1)
+	}
+
+	if !madeProgress {
+		log.Println("Training interrupted before any progress; not saving a checkpoint.")
+		os.Exit(1)
+	}
+	checkpointPath := flag.Arg(ModelPathArg) + ".checkpoint"
+	log.Println("Training interrupted; saving checkpoint to", checkpointPath)
+	saveModel(model, checkpointPath)
+	os.Exit(1)
+}
+
+// readWordVectors loads word vectors by their absolute
+// path, so that a model which refers to them can be
+// loaded from any directory.
+func readWordVectors(path string, maxWords int) *sentigraph.WordVectors {
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Failed to find word vectors:", err)
+		os.Exit(1)
+	}
+	log.Println("Loading word vectors...")
+	vectors, err := sentigraph.LoadWordVectors(absPath, maxWords)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Failed to read word vectors:", err)
+		os.Exit(1)
+	}
+	log.Println("Loaded", len(vectors.Words), "word vectors.")
+	return vectors
+}
 
+func saveModel(model serializer.Serializer, path string) {
 	data, err := serializer.SerializeWithType(model)
 	if err != nil {
 		fmt.Fprintln(os.Stderr, "Failed to serialize model:", err)
 		os.Exit(1)
 	}
-	if err := ioutil.WriteFile(os.Args[ModelPathArg], data, 0755); err != nil {
+	if err := ioutil.WriteFile(path, data, 0755); err != nil {
 		fmt.Fprintln(os.Stderr, "Failed to write model file:", err)
 		os.Exit(1)
 	}
 }
 
+func printUsage() {
+	fmt.Fprintln(os.Stderr, "Usage:", os.Args[0], "[flags] model_name model_path data.csv")
+	fmt.Fprintln(os.Stderr, "\nAvailable models:")
+	for _, model := range modelNames() {
+		fmt.Fprintln(os.Stderr, " -", model)
+	}
+	fmt.Fprintln(os.Stderr, "\nFlags:")
+	flag.PrintDefaults()
+	fmt.Fprintln(os.Stderr)
+}
+
 func modelNames() []string {
 	var res []string
 	for model := range sentigraph.Models {
 		res = append(res, model)
 	}
+	for model := range sentigraph.EmotionModels {
+		res = append(res, model)
+	}
 	sort.Strings(res)
 	return res
 }
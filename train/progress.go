@@ -0,0 +1,58 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/unixpickle/sentigraph"
+)
+
+const progressBarWidth = 30
+
+// progressBar prints a progress bar with an estimated
+// time remaining for each stage of training.
+type progressBar struct {
+	stage      string
+	stageStart time.Time
+}
+
+// Update redraws the progress bar.
+func (p *progressBar) Update(prog sentigraph.TrainProgress) {
+	if prog.Stage != p.stage {
+		p.Finish()
+		p.stage = prog.Stage
+		p.stageStart = time.Now()
+	}
+	if prog.Total == 0 {
+		return
+	}
+
+	frac := float64(prog.Done) / float64(prog.Total)
+	filled := int(frac * progressBarWidth)
+	bar := strings.Repeat("=", filled) + strings.Repeat(" ", progressBarWidth-filled)
+
+	eta := "?"
+	if prog.Done > 0 {
+		elapsed := time.Since(p.stageStart)
+		remaining := time.Duration(float64(elapsed) * (1 - frac) / frac)
+		eta = remaining.Round(time.Second).String()
+	}
+
+	status := fmt.Sprintf("\r[%s] %3.0f%% %d/%d %s, ETA %s", bar, frac*100,
+		prog.Done, prog.Total, prog.Stage, eta)
+	if prog.Loss != 0 {
+		status += fmt.Sprintf(", loss %.4f", prog.Loss)
+	}
+	fmt.Fprint(os.Stderr, status+"    ")
+}
+
+// Finish ends the current line, if a progress bar has
+// been drawn on it.
+func (p *progressBar) Finish() {
+	if p.stage != "" {
+		fmt.Fprintln(os.Stderr)
+		p.stage = ""
+	}
+}
@@ -0,0 +1,296 @@
+package sentigraph
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// WordVectors stores pretrained word embeddings, such as
+// those produced by GloVe or word2vec.
+//
+// Since the embeddings are trained on far more text than
+// any sentiment corpus, models which use them can handle
+// words that never appear in their training data.
+//
+// Vectors which were loaded from a file are saved as a
+// reference to the file, along with the vectors of the
+// words seen in training (see Keep).
+// When they are decoded, the file is loaded again if it
+// still exists, and otherwise only the saved vectors are
+// available.
+type WordVectors struct {
+	// Dim is the dimensionality of the vectors.
+	Dim int
+
+	// Words lists the words in the vocabulary.
+	// The vector for Words[i] is stored at
+	// Vectors[i*Dim : (i+1)*Dim].
+	Words   []string
+	Vectors packedFloats
+
+	// Path and MaxWords record where the vectors were
+	// loaded from, if they were loaded with
+	// LoadWordVectors.
+	Path     string `json:",omitempty"`
+	MaxWords int    `json:",omitempty"`
+
+	// TFIDF is true if the words of a text should be
+	// weighted by their TF-IDF rather than averaged
+	// uniformly.
+	TFIDF bool
+
+	// IDF stores the inverse document frequency of each
+	// word in the training corpus, as computed by FitIDF.
+	// Words outside the corpus are treated as if they
+	// appeared in one document more than the corpus has.
+	IDF       map[string]float64 `json:",omitempty"`
+	UnseenIDF float64            `json:",omitempty"`
+
+	index map[string]int
+
+	// kept stores the words whose vectors are saved with
+	// a Path, or is nil if every vector is saved.
+	kept map[string]bool
+}
+
+// LoadWordVectors reads embeddings from a file with
+// ReadWordVectors, and records the file's path so that
+// the vectors can be saved by reference.
+func LoadWordVectors(path string, maxWords int) (*WordVectors, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	res, err := ReadWordVectors(f, maxWords)
+	if err != nil {
+		return nil, err
+	}
+	res.Path = path
+	res.MaxWords = maxWords
+	return res, nil
+}
+
+// ReadWordVectors reads embeddings in the text format
+// used by GloVe and word2vec: each line has a word
+// followed by the components of its vector, separated by
+// spaces.
+// The header line of the word2vec format, which gives
+// the number of words and the dimensionality, is
+// skipped.
+//
+// If maxWords is non-zero, only the first maxWords words
+// are read.
+// Both formats list the most frequent words first, so
+// this keeps the most useful vectors.
+func ReadWordVectors(r io.Reader, maxWords int) (*WordVectors, error) {
+	res := &WordVectors{index: map[string]int{}}
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, 1<<20)
+	var lineNum int
+	for scanner.Scan() {
+		lineNum++
+		if maxWords > 0 && len(res.Words) == maxWords {
+			break
+		}
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		} else if lineNum == 1 && len(fields) == 2 {
+			// Skip the word2vec header.
+			continue
+		}
+		if res.Dim == 0 {
+			res.Dim = len(fields) - 1
+		} else if len(fields)-1 != res.Dim {
+			return nil, fmt.Errorf("line %d: expected %d components but got %d",
+				lineNum, res.Dim, len(fields)-1)
+		}
+		word := fields[0]
+		if _, ok := res.index[word]; ok {
+			continue
+		}
+		for _, field := range fields[1:] {
+			x, err := strconv.ParseFloat(field, 32)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %s", lineNum, err)
+			}
+			res.Vectors = append(res.Vectors, float32(x))
+		}
+		res.index[word] = len(res.Words)
+		res.Words = append(res.Words, word)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(res.Words) == 0 {
+		return nil, errors.New("no word vectors")
+	}
+	return res, nil
+}
+
+// FitIDF computes the inverse document frequency of each
+// word from a corpus, for use in TF-IDF weighting.
+func (w *WordVectors) FitIDF(texts []string) {
+	docFreqs := map[string]int{}
+	for _, text := range texts {
+		seen := map[string]bool{}
+		for _, word := range w.tokens(text) {
+			if !seen[word] {
+				seen[word] = true
+				docFreqs[word]++
+			}
+		}
+	}
+	w.IDF = map[string]float64{}
+	numDocs := float64(len(texts) + 1)
+	for word, freq := range docFreqs {
+		if _, ok := w.index[word]; ok {
+			w.IDF[word] = math.Log(numDocs / float64(freq+1))
+		}
+	}
+	w.UnseenIDF = math.Log(numDocs)
+}
+
+// Embed computes the (possibly TF-IDF weighted) average of
+// the vectors of the words in the text.
+// Words without vectors are ignored, and the result is
+// all zeros if no word has a vector.
+func (w *WordVectors) Embed(text string) []float64 {
+	res := make([]float64, w.Dim)
+	var totalWeight float64
+	for _, word := range w.tokens(text) {
+		idx, ok := w.index[word]
+		if !ok {
+			continue
+		}
+		weight := 1.0
+		if w.TFIDF && w.IDF != nil {
+			if idf, ok := w.IDF[word]; ok {
+				weight = idf
+			} else {
+				weight = w.UnseenIDF
+			}
+		}
+		vec := w.Vectors[idx*w.Dim : (idx+1)*w.Dim]
+		for i, x := range vec {
+			res[i] += weight * float64(x)
+		}
+		totalWeight += weight
+	}
+	if totalWeight > 0 {
+		for i := range res {
+			res[i] /= totalWeight
+		}
+	}
+	return res
+}
+
+// ResetKept forgets which words have been kept, so that
+// no vectors are saved with a Path until Keep is called.
+func (w *WordVectors) ResetKept() {
+	w.kept = map[string]bool{}
+}
+
+// Keep marks the words of a training text, so that their
+// vectors are saved with the model.
+// It has no effect until ResetKept is called.
+func (w *WordVectors) Keep(text string) {
+	if w.kept == nil {
+		return
+	}
+	for _, word := range w.tokens(text) {
+		if _, ok := w.index[word]; ok {
+			w.kept[word] = true
+		}
+	}
+}
+
+// MarshalJSON encodes the vectors.
+// If the vectors have a Path, only the kept vectors are
+// encoded.
+func (w *WordVectors) MarshalJSON() ([]byte, error) {
+	type rawWordVectors WordVectors
+	raw := rawWordVectors(*w)
+	if w.Path != "" && w.kept != nil {
+		raw.Words = nil
+		raw.Vectors = nil
+		for i, word := range w.Words {
+			if w.kept[word] {
+				raw.Words = append(raw.Words, word)
+				raw.Vectors = append(raw.Vectors, w.Vectors[i*w.Dim:(i+1)*w.Dim]...)
+			}
+		}
+	}
+	return json.Marshal(&raw)
+}
+
+// UnmarshalJSON decodes the vectors and rebuilds the
+// vocabulary index.
+// If the vectors have a Path, the file is loaded again.
+func (w *WordVectors) UnmarshalJSON(d []byte) error {
+	type rawWordVectors WordVectors
+	var raw rawWordVectors
+	if err := json.Unmarshal(d, &raw); err != nil {
+		return err
+	}
+	*w = WordVectors(raw)
+	if len(w.Vectors) != len(w.Words)*w.Dim {
+		return errors.New("word vector size mismatch")
+	}
+	w.index = map[string]int{}
+	for i, word := range w.Words {
+		w.index[word] = i
+	}
+	if w.Path == "" {
+		return nil
+	}
+
+	// Keep the saved words when the model is saved again.
+	w.kept = map[string]bool{}
+	for _, word := range w.Words {
+		w.kept[word] = true
+	}
+	full, err := LoadWordVectors(w.Path, w.MaxWords)
+	if err == nil && full.Dim != w.Dim {
+		err = fmt.Errorf("%s has %d dimensions instead of %d", w.Path, full.Dim, w.Dim)
+	}
+	if err != nil {
+		log.Println("Using only the saved word vectors:", err)
+		return nil
+	}
+	w.Words, w.Vectors, w.index = full.Words, full.Vectors, full.index
+	return nil
+}
+
+// tokens splits the text into words.
+// Each word is used as-is if it has a vector, since some
+// vectors are case sensitive; otherwise it is normalized.
+func (w *WordVectors) tokens(text string) []string {
+	var res []string
+	for _, word := range strings.Fields(text) {
+		raw := strings.Fields(SeparatePunctuation(word))
+		normalized := strings.Fields(SeparatePunctuation(Normalize(word)))
+		if len(raw) != len(normalized) {
+			// Usernames and URLs are replaced entirely.
+			res = append(res, normalized...)
+			continue
+		}
+		for i, token := range raw {
+			if _, ok := w.index[token]; ok {
+				res = append(res, token)
+			} else {
+				res = append(res, normalized[i])
+			}
+		}
+	}
+	return res
+}